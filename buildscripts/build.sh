@@ -78,7 +78,8 @@ if [ $GOOS = "windows" ]; then
     output_name+='.exe'
 fi
 
-env GOOS=$GOOS GOARCH=$GOARCH go build ${BUILD_TAG} -o $output_name .
+# PKGPATH is the main package to build, the repository root by default
+env GOOS=$GOOS GOARCH=$GOARCH go build ${BUILD_TAG} -o $output_name ${PKGPATH:-.}
 
 echo ""
 
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	goflag "flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ksraj123/lister-sa/pkg/cmd"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/printers"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+// flags shared by all subcommands, on top of the kubeconfig and namespace flags of kubectl
+type pluginFlags struct {
+	configFlags   *genericclioptions.ConfigFlags
+	allNamespaces bool
+	output        string
+	limits        limits.Limits
+}
+
+func main() {
+	runLimits, err := limits.FromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	flags := &pluginFlags{configFlags: genericclioptions.NewConfigFlags(true), limits: runLimits}
+	root := &cobra.Command{
+		Use:          "pvc-cleaner",
+		Short:        "Find and clean up dangling StatefulSet PVCs",
+		SilenceUsage: true,
+	}
+	flags.configFlags.AddFlags(root.PersistentFlags())
+	root.PersistentFlags().BoolVarP(&flags.allNamespaces, "all-namespaces", "A", false, "look for dangling PVCs in all namespaces")
+	root.PersistentFlags().StringVarP(&flags.output, "output", "o", printers.TABLE, "output format, one of table, json or yaml")
+
+	var planFile string
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show which of the selected PVCs are dangling and what clean would do with them",
+		Args:  cobra.NoArgs,
+		RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+			return cmd.Plan(ctx, o, planFile)
+		}),
+	}
+	planCmd.Flags().StringVar(&planFile, "out", "", "file to save the plan to, for a later apply")
+	planCmd.Flags().BoolVar(&flags.limits.Force, "force", false, "plan the PVCs of namespaces that failed the safety check as if it passed")
+
+	var dryRun bool
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Clean up the dangling PVCs",
+		Args:  cobra.NoArgs,
+		RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+			if dryRun {
+				return cmd.Plan(ctx, o, "")
+			}
+			return cmd.Clean(ctx, o)
+		}),
+	}
+	cleanCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print the plan, nothing is changed")
+	limitFlags := goflag.NewFlagSet("limits", goflag.ContinueOnError)
+	flags.limits.AddFlags(limitFlags)
+	cleanCmd.Flags().AddGoFlagSet(limitFlags)
+
+	applyCmd := &cobra.Command{
+		Use:   "apply <plan file>",
+		Short: "Act only on the dangling PVCs of a saved plan that did not change since",
+		Args:  cobra.ExactArgs(1),
+		RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+			return cmd.Apply(ctx, o, args[0])
+		}),
+	}
+	applyCmd.Flags().AddGoFlagSet(limitFlags)
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "dangling",
+			Short: "List the dangling StatefulSet PVCs",
+			Args:  cobra.NoArgs,
+			RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+				return cmd.Dangling(ctx, o)
+			}),
+		},
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the StatefulSet PVCs selected for cleanup",
+			Args:  cobra.NoArgs,
+			RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+				return cmd.List(ctx, o)
+			}),
+		},
+		planCmd,
+		applyCmd,
+		cleanCmd,
+		&cobra.Command{
+			Use:   "report",
+			Short: "Show the storage held by dangling PVCs per namespace, storage class and node",
+			Args:  cobra.NoArgs,
+			RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+				return cmd.Report(ctx, o)
+			}),
+		},
+		&cobra.Command{
+			Use:   "explain <namespace>/<pvc>",
+			Short: "Show every rule that makes the PVC eligible or ineligible for cleanup",
+			Args:  cobra.ExactArgs(1),
+			RunE: flags.run(func(ctx context.Context, o *cmd.Options, args []string) error {
+				return cmd.Explain(ctx, o, args[0])
+			}),
+		},
+	)
+
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run creates the options from the kubectl flags before running the subcommand
+func (f *pluginFlags) run(command func(context.Context, *cmd.Options, []string) error) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, args []string) error {
+		if err := printers.ValidateFormat(f.output); err != nil {
+			return err
+		}
+		if err := f.limits.Validate(); err != nil {
+			return err
+		}
+		config, err := f.configFlags.ToRESTConfig()
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		namespaces, err := f.namespaces(ctx)
+		if err != nil {
+			return err
+		}
+		options, err := cmd.NewOptions(config, namespaces, f.output, c.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		options.Limits = f.limits
+		return command(ctx, options, args)
+	}
+}
+
+// the namespace of -n or the current context, or every namespace with -A
+func (f *pluginFlags) namespaces(ctx context.Context) ([]string, error) {
+	if !f.allNamespaces {
+		namespace, _, err := f.configFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, err
+		}
+		return []string{namespace}, nil
+	}
+	config, err := f.configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	namespaceList, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list namespaces, %v", err.Error())
+	}
+	var namespaces []string
+	for _, namespace := range namespaceList.Items {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	return namespaces, nil
+}
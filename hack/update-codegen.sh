@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+# This script regenerates the deepcopy functions and the clientset of the pvc-cleaner.openebs.io API.
+# client-gen and deepcopy-gen from k8s.io/code-generator need to be present in PATH.
+set -e
+
+MODULE=github.com/ksraj123/lister-sa
+APIS=${MODULE}/pkg/apis/pvccleaner/v1alpha1
+
+# Get the parent directory of where this script is.
+SOURCE="${BASH_SOURCE[0]}"
+while [ -h "$SOURCE" ] ; do SOURCE="$(readlink "$SOURCE")"; done
+DIR="$( cd -P "$( dirname "$SOURCE" )/../" && pwd )"
+
+# code-generator expects the module to be laid out under GOPATH
+OUTPUT_BASE=$(mktemp -d)
+mkdir -p "${OUTPUT_BASE}/$(dirname ${MODULE})"
+ln -s "${DIR}" "${OUTPUT_BASE}/${MODULE}"
+trap 'rm -rf "${OUTPUT_BASE}"' EXIT
+
+cd "${OUTPUT_BASE}/${MODULE}"
+
+echo "==> Generating deepcopy functions..."
+deepcopy-gen --input-dirs ${APIS} \
+  -O zz_generated.deepcopy \
+  --go-header-file hack/boilerplate.go.txt \
+  --output-base "${OUTPUT_BASE}"
+
+echo "==> Generating clientset..."
+client-gen --clientset-name versioned \
+  --input-base "" \
+  --input ${APIS} \
+  --output-package ${MODULE}/pkg/client/clientset \
+  --go-header-file hack/boilerplate.go.txt \
+  --output-base "${OUTPUT_BASE}"
@@ -2,35 +2,157 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ksraj123/lister-sa/pkg/cmd"
 	"github.com/ksraj123/lister-sa/pkg/constants"
-	"github.com/ksraj123/lister-sa/pkg/executor"
-	"github.com/ksraj123/lister-sa/pkg/utils"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	"github.com/ksraj123/lister-sa/pkg/controller"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/printers"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-var (
-	clientset *kubernetes.Clientset
-	ctx       context.Context
-)
+const usage = `Usage: %v [command] [flags]
+
+Commands:
+  list                       list the StatefulSet PVCs selected for cleanup
+  plan                       show which of the selected PVCs are dangling and what clean would do with them,
+                             --out saves the plan to a file
+  apply <plan file>          act only on the dangling PVCs of a saved plan that did not change since
+  clean                      clean up the dangling PVCs, the default if no command is given
+  run                        clean up every --interval or on --schedule until stopped, with --leader-elect only while leading
+  report                     show the storage held by dangling PVCs per namespace, storage class and node
+  explain <namespace>/<pvc>  show every rule that makes the PVC eligible or ineligible for cleanup
+
+Flags:
+`
+
+func main() {
+	command := "clean"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, os.Args[0])
+		flags.PrintDefaults()
+	}
+	kubeconfig := flags.String("kubeconfig", "", "path to a kubeconfig, the in-cluster config is used if not set")
+	namespaces := flags.String("namespaces", os.Getenv(constants.NAMESPACES_ENV_VAR), "comma separated namespaces to look for dangling PVCs in, defaults to the "+constants.NAMESPACES_ENV_VAR+" environment variable")
+	var output string
+	flags.StringVar(&output, "output", printers.TABLE, "output format of list, plan, report and explain, one of table, json or yaml")
+	flags.StringVar(&output, "o", printers.TABLE, "shorthand for --output")
+	planFile := flags.String("out", "", "file the plan command saves the plan to, for a later apply")
+	interval := flags.Duration("interval", time.Hour, "time between the cleanup runs of the run command")
+	schedule := flags.String("schedule", "", "cron expression of the cleanup runs of the run command, such as \"*/15 * * * *\", instead of --interval")
+	jitter := flags.Duration("jitter", 0, "maximum random delay of each scheduled cleanup run")
+	healthAddr := flags.String("health-addr", ":8080", "address the run command serves /healthz and /readyz on, empty to serve nothing")
+	debugAddr := flags.String("debug-addr", "127.0.0.1:8081", "address the run command serves the unauthenticated /debug/plan and /debug/pprof on, empty to serve nothing")
+	readyWindow := flags.Duration("ready-window", 0, "how long ago the last successful run of the leader may be for /readyz, twice the time between runs plus the jitter by default")
+	lastUsedThreshold := flags.Duration("last-used-threshold", time.Hour, "how old the pvc-cleaner.openebs.io/last-used annotation of a mounted PVC has to be before the run command stamps it again")
+	leaderElect := flags.Bool("leader-elect", false, "elect a leader through a Lease among the replicas of the run command, only the leader cleans up")
+	leaseName := flags.String("leader-election-id", constants.LEASE_NAME, "name of the leader election Lease")
+	leaseNamespace := flags.String("leader-election-namespace", envOrDefault(constants.POD_NAMESPACE_ENV_VAR, "default"), "namespace of the leader election Lease, defaults to the "+constants.POD_NAMESPACE_ENV_VAR+" environment variable")
+	requestTimeout := flags.Duration("request-timeout", 30*time.Second, "timeout of each API call, 0 for no timeout")
+	runLimits, err := limits.FromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	runLimits.AddFlags(flags)
+	flags.Parse(args)
+	if err := printers.ValidateFormat(output); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if err := runLimits.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
 
-func init() {
-	config, err := rest.InClusterConfig()
+	if *namespaces == "" {
+		fmt.Fprintf(os.Stderr, "Environment Variable %v not found and --namespaces not set\n", constants.NAMESPACES_ENV_VAR)
+		os.Exit(1)
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
-		fmt.Printf("error %s, getting inclusterconfig", err.Error())
+		fmt.Fprintf(os.Stderr, "error %s, building kubeconfig\n", err.Error())
+		os.Exit(1)
 	}
-	clientset, err = kubernetes.NewForConfig(config)
+	config.Timeout = *requestTimeout
+	options, err := cmd.NewOptions(config, strings.Split(*namespaces, ","), output, os.Stdout)
 	if err != nil {
-		fmt.Printf("error %s, creating clientset\n", err.Error())
+		fmt.Fprintf(os.Stderr, "error %s, creating clients\n", err.Error())
+		os.Exit(1)
+	}
+	options.Limits = runLimits
+
+	// on SIGTERM no further deletions are started, the started ones finish and what was done is still reported.
+	// The run command also releases the leader election Lease
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	switch command {
+	case "list":
+		err = cmd.List(ctx, options)
+	case "plan":
+		err = cmd.Plan(ctx, options, *planFile)
+	case "apply":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = cmd.Apply(ctx, options, flags.Arg(0))
+	case "clean":
+		if os.Getenv(constants.REPORT_ENV_VAR) == "true" {
+			err = cmd.Report(ctx, options)
+		}
+		if err == nil {
+			err = cmd.Clean(ctx, options)
+		}
+	case "run":
+		err = cmd.Run(ctx, options, cmd.RunOptions{
+			Schedule: *schedule,
+			Interval: *interval,
+			Jitter:   *jitter,
+			LeaderElection: controller.LeaderElection{
+				Enabled:        *leaderElect,
+				LeaseName:      *leaseName,
+				LeaseNamespace: *leaseNamespace,
+			},
+			HealthAddr:        *healthAddr,
+			DebugAddr:         *debugAddr,
+			ReadyWindow:       *readyWindow,
+			LastUsedThreshold: *lastUsedThreshold,
+		})
+	case "report":
+		err = cmd.Report(ctx, options)
+	case "explain":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = cmd.Explain(ctx, options, flags.Arg(0))
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error %s\n", err.Error())
+		os.Exit(1)
 	}
-	ctx = context.Background()
 }
 
-func main() {
-	namespaces := utils.EnvVarSlice(constants.NAMESPACES_ENV_VAR)
-	for _, namespace := range namespaces {
-		executor.Execute(clientset, ctx, namespace)
+func envOrDefault(envVar string, defaultValue string) string {
+	if value, exists := os.LookupEnv(envVar); exists {
+		return value
 	}
+	return defaultValue
 }
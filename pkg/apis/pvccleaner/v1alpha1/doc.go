@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+// +groupName=pvc-cleaner.openebs.io
+// +groupGoName=PVCCleaner
+
+// Package v1alpha1 contains the v1alpha1 API of the pvc-cleaner.openebs.io group
+package v1alpha1
@@ -0,0 +1,123 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CleanupAction is what happens to a dangling PVC selected by a policy
+type CleanupAction string
+
+const (
+	// Delete removes the dangling PVC
+	Delete CleanupAction = "Delete"
+	// Snapshot takes a VolumeSnapshot of the dangling PVC and removes the PVC once the snapshot is ready to use
+	Snapshot CleanupAction = "Snapshot"
+	// Quarantine labels the dangling PVC and leaves it in place for manual review
+	Quarantine CleanupAction = "Quarantine"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PVCCleanupPolicy selects StorageClasses, namespaces and StatefulSets whose dangling PVCs should be acted upon
+type PVCCleanupPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PVCCleanupPolicySpec   `json:"spec"`
+	Status PVCCleanupPolicyStatus `json:"status,omitempty"`
+}
+
+type PVCCleanupPolicySpec struct {
+	// StorageClassSelector matches the labels of StorageClasses, nil or empty selects every StorageClass of the configured provisioners
+	StorageClassSelector *metav1.LabelSelector `json:"storageClassSelector,omitempty"`
+	// NamespaceSelector matches the labels of namespaces, nil or empty selects every namespace
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// StatefulSetSelector matches the StatefulSet selector labels that Kubernetes copies onto the StatefulSet PVCs,
+	// nil falls back to the sts-pvc-selector parameter of the StorageClass
+	StatefulSetSelector *metav1.LabelSelector `json:"statefulSetSelector,omitempty"`
+	// Action to take on a dangling PVC, defaults to Delete
+	Action CleanupAction `json:"action,omitempty"`
+	// GracePeriod a PVC has to stay dangling before the action is taken
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+	// UnusedFor is how long ago a PVC has to have been last mounted before the action is taken, as recorded in its
+	// last-used annotation by the run command. A PVC that was never seen mounted counts from the time it was found dangling
+	UnusedFor *metav1.Duration `json:"unusedFor,omitempty"`
+	// VolumeSnapshotClassName used when Action is Snapshot
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+type PVCCleanupPolicyStatus struct {
+	// LastRunTime is the time at which the policy was last evaluated
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Selected is the number of StatefulSet PVCs the policy selected in the last run
+	Selected int32 `json:"selected"`
+	// Dangling is the number of selected PVCs that were found dangling in the last run
+	Dangling int32 `json:"dangling"`
+	// Deleted is the number of PVCs deleted in the last run
+	Deleted int32 `json:"deleted"`
+	// Snapshotted is the number of VolumeSnapshots created in the last run
+	Snapshotted int32 `json:"snapshotted"`
+	// Quarantined is the number of PVCs quarantined in the last run
+	Quarantined int32 `json:"quarantined"`
+	// Failed is the number of actions that failed in the last run
+	Failed int32 `json:"failed"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PVCCleanupPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []PVCCleanupPolicy `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PVCCleanupRun is the audit record of the PVCs deleted in one run of the cleaner
+type PVCCleanupRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PVCCleanupRunSpec `json:"spec"`
+}
+
+type PVCCleanupRunSpec struct {
+	StartTime metav1.Time `json:"startTime"`
+	// CompletionTime is unset while the run is in progress or if it did not finish
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Interrupted is set if the run was stopped before all its deletions were started
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Deletions are appended as the PVCs get deleted
+	Deletions []PVCDeletion `json:"deletions,omitempty"`
+	// ContinuedIn is the PVCCleanupRun the deletions of the run are recorded in once this one is full
+	ContinuedIn string `json:"continuedIn,omitempty"`
+}
+
+// PVCDeletion records a deleted PVC as it was right before deletion
+type PVCDeletion struct {
+	Namespace        string      `json:"namespace"`
+	Name             string      `json:"name"`
+	UID              types.UID   `json:"uid"`
+	Size             string      `json:"size,omitempty"`
+	StorageClassName string      `json:"storageClassName,omitempty"`
+	VolumeName       string      `json:"volumeName,omitempty"`
+	StatefulSet      string      `json:"statefulSet,omitempty"`
+	Reason           string      `json:"reason"`
+	DeletionTime     metav1.Time `json:"deletionTime"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PVCCleanupRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []PVCCleanupRun `json:"items"`
+}
@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/statefulsetpvcs"
+	"github.com/ksraj123/lister-sa/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Recorder persists the PVCs deleted in one run as a PVCCleanupRun. The PVCCleanupRun is created on the first deletion
+// and updated after every following one, so that a run that gets interrupted still leaves a record of what it deleted.
+// Deletions that could not be saved stay pending and are saved along with the next one or by Complete. Once a
+// PVCCleanupRun holds MAX_RUN_DELETIONS deletions, the following ones go to a new PVCCleanupRun it is continued in
+type Recorder struct {
+	cleanerClientset versioned.Interface
+	startTime        metav1.Time
+	maxDeletions     int
+	// Record and Complete may be called from concurrent deletions, the lock guards the fields below and is never held
+	// across API calls
+	lock    sync.Mutex
+	run     *v1alpha1.PVCCleanupRun
+	pending []v1alpha1.PVCDeletion
+	// saving is set while the pending deletions are being saved, a concurrent deletion is left pending for that save
+	saving bool
+}
+
+// NewRecorder returns a nil Recorder, which records nothing, if there is no cleanerClientset
+func NewRecorder(cleanerClientset versioned.Interface) *Recorder {
+	if cleanerClientset == nil {
+		return nil
+	}
+	return &Recorder{
+		cleanerClientset: cleanerClientset,
+		startTime:        metav1.Now(),
+		maxDeletions:     constants.MAX_RUN_DELETIONS,
+	}
+}
+
+// Record appends the deletion of the PVC to the run. A nil Recorder records nothing
+func (r *Recorder) Record(ctx context.Context, pvc *v1.PersistentVolumeClaim, reason string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	r.pending = append(r.pending, NewDeletion(pvc, reason))
+	r.lock.Unlock()
+	if err := r.flush(ctx); err != nil {
+		fmt.Printf("Could not record deletion of PVC %v in namespace %v yet, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+	}
+}
+
+// Complete saves the deletions still pending and sets the completion time of the run, if anything was recorded. If ctx
+// is done the run is marked interrupted and still completed. Returns an error if some deletions could not be recorded
+func (r *Recorder) Complete(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	interrupted := ctx.Err() != nil
+	ctx, cancel := utils.FinishContext(ctx)
+	defer cancel()
+	if err := r.flush(ctx); err != nil {
+		fmt.Printf("Could not record deleted PVCs, Error = %v\n", err.Error())
+	}
+
+	r.lock.Lock()
+	run, unrecorded := r.run, len(r.pending)
+	r.lock.Unlock()
+	if run != nil {
+		completed, err := r.update(ctx, run, func(run *v1alpha1.PVCCleanupRun) {
+			now := metav1.Now()
+			run.Spec.CompletionTime = &now
+			run.Spec.Interrupted = interrupted
+		})
+		if err != nil {
+			fmt.Printf("Could not complete PVCCleanupRun %v, Error = %v\n", run.Name, err.Error())
+		} else {
+			r.lock.Lock()
+			r.run = completed
+			r.lock.Unlock()
+			fmt.Printf("Deleted PVCs recorded in PVCCleanupRun %v\n", run.Name)
+		}
+	}
+	if unrecorded != 0 {
+		return fmt.Errorf("%v deleted PVCs could not be recorded in a PVCCleanupRun", unrecorded)
+	}
+	return nil
+}
+
+// Unrecorded is the number of deletions that could not be saved so far
+func (r *Recorder) Unrecorded() int {
+	if r == nil {
+		return 0
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.pending)
+}
+
+// saves the pending deletions in batches that fit in the current PVCCleanupRun, unless another save is in progress
+func (r *Recorder) flush(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.saving {
+		return nil
+	}
+	r.saving = true
+	defer func() {
+		r.saving = false
+	}()
+	for len(r.pending) != 0 {
+		run := r.run
+		size := r.maxDeletions
+		if run != nil && len(run.Spec.Deletions) < r.maxDeletions {
+			size -= len(run.Spec.Deletions)
+		}
+		if size > len(r.pending) {
+			size = len(r.pending)
+		}
+		batch := append([]v1alpha1.PVCDeletion{}, r.pending[:size]...)
+
+		r.lock.Unlock()
+		saved, err := r.save(ctx, run, batch)
+		r.lock.Lock()
+		if err != nil {
+			return err
+		}
+		r.run = saved
+		r.pending = r.pending[size:]
+	}
+	return nil
+}
+
+// appends the batch to the run, or to a new run if there is none yet or it is full
+func (r *Recorder) save(ctx context.Context, run *v1alpha1.PVCCleanupRun, batch []v1alpha1.PVCDeletion) (*v1alpha1.PVCCleanupRun, error) {
+	if run != nil && len(run.Spec.Deletions) < r.maxDeletions {
+		return r.update(ctx, run, func(run *v1alpha1.PVCCleanupRun) {
+			run.Spec.Deletions = append(run.Spec.Deletions, batch...)
+		})
+	}
+	next := &v1alpha1.PVCCleanupRun{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "pvc-cleanup-"},
+		Spec:       v1alpha1.PVCCleanupRunSpec{StartTime: r.startTime, Deletions: batch},
+	}
+	created, err := r.cleanerClientset.PVCCleanerV1alpha1().PVCCleanupRuns().Create(ctx, next, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if run != nil {
+		_, err := r.update(ctx, run, func(run *v1alpha1.PVCCleanupRun) {
+			run.Spec.ContinuedIn = created.Name
+		})
+		if err != nil {
+			fmt.Printf("Could not link PVCCleanupRun %v to %v, Error = %v\n", run.Name, created.Name, err.Error())
+		}
+	}
+	return created, nil
+}
+
+// applies the mutation to the latest version of the run, retrying on conflicts
+func (r *Recorder) update(ctx context.Context, run *v1alpha1.PVCCleanupRun, mutate func(*v1alpha1.PVCCleanupRun)) (*v1alpha1.PVCCleanupRun, error) {
+	runs := r.cleanerClientset.PVCCleanerV1alpha1().PVCCleanupRuns()
+	latest := run
+	var updated *v1alpha1.PVCCleanupRun
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		run := latest.DeepCopy()
+		mutate(run)
+		var err error
+		updated, err = runs.Update(ctx, run, metav1.UpdateOptions{})
+		if err == nil {
+			return nil
+		}
+		if current, getErr := runs.Get(ctx, latest.Name, metav1.GetOptions{}); getErr == nil {
+			latest = current
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func NewDeletion(pvc *v1.PersistentVolumeClaim, reason string) v1alpha1.PVCDeletion {
+	deletion := v1alpha1.PVCDeletion{
+		Namespace:    pvc.Namespace,
+		Name:         pvc.Name,
+		UID:          pvc.UID,
+		VolumeName:   pvc.Spec.VolumeName,
+		StatefulSet:  statefulsetpvcs.OwnerStatefulSet(pvc),
+		Reason:       reason,
+		DeletionTime: metav1.Now(),
+	}
+	if pvc.Spec.StorageClassName != nil {
+		deletion.StorageClassName = *pvc.Spec.StorageClassName
+	}
+	if size, exists := pvc.Status.Capacity[v1.ResourceStorage]; exists {
+		deletion.Size = size.String()
+	} else if size, exists := pvc.Spec.Resources.Requests[v1.ResourceStorage]; exists {
+		deletion.Size = size.String()
+	}
+	return deletion
+}
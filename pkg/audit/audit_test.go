@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	cleanerfake "github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/fake"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// a fake cleaner clientset that names created runs after their generateName, failing the first creates and updates
+func newCleanerClientset(failCreates int, conflictUpdates int, failUpdates int) *cleanerfake.Clientset {
+	cleanerClientset := cleanerfake.NewSimpleClientset()
+	created := 0
+	cleanerClientset.PrependReactor("create", "pvccleanupruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failCreates > 0 {
+			failCreates--
+			return true, nil, fmt.Errorf("create failed")
+		}
+		run := action.(k8stesting.CreateAction).GetObject().(*v1alpha1.PVCCleanupRun)
+		created++
+		run.Name = fmt.Sprintf("%v%v", run.GenerateName, created)
+		return false, nil, nil
+	})
+	cleanerClientset.PrependReactor("update", "pvccleanupruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflictUpdates > 0 {
+			conflictUpdates--
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "pvccleanupruns"}, "", fmt.Errorf("conflict"))
+		}
+		if failUpdates > 0 {
+			failUpdates--
+			return true, nil, fmt.Errorf("update failed")
+		}
+		return false, nil, nil
+	})
+	return cleanerClientset
+}
+
+func listRuns(t *testing.T, cleanerClientset *cleanerfake.Clientset) map[string]v1alpha1.PVCCleanupRun {
+	runs, err := cleanerClientset.PVCCleanerV1alpha1().PVCCleanupRuns().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]v1alpha1.PVCCleanupRun)
+	for _, run := range runs.Items {
+		byName[run.Name] = run
+	}
+	return byName
+}
+
+func TestRecorder(t *testing.T) {
+	tests := map[string]struct {
+		deletions       int
+		maxDeletions    int
+		failCreates     int
+		conflictUpdates int
+		failUpdates     int
+		cancelled       bool
+		// expectedRuns are the number of deletions recorded in each run, pvc-cleanup-1 first
+		expectedRuns        []int
+		expectedInterrupted bool
+		expectedUnrecorded  int
+	}{
+		"Run is created on the first deletion and updated on the next": {
+			deletions:    2,
+			expectedRuns: []int{2},
+		},
+		"Update is retried on conflict": {
+			deletions:       2,
+			conflictUpdates: 1,
+			expectedRuns:    []int{2},
+		},
+		"Deletion that failed to be created is saved with the next one": {
+			deletions:    2,
+			failCreates:  1,
+			expectedRuns: []int{2},
+		},
+		"Deletion that failed to be saved is saved on complete": {
+			deletions:    2,
+			failUpdates:  1,
+			expectedRuns: []int{2},
+		},
+		"Deletions that can not be saved are reported": {
+			deletions:          1,
+			failCreates:        2,
+			expectedUnrecorded: 1,
+		},
+		"Full run is continued in a new one": {
+			deletions:    5,
+			maxDeletions: 2,
+			expectedRuns: []int{2, 2, 1},
+		},
+		"Interrupted run is still completed": {
+			deletions:           1,
+			cancelled:           true,
+			expectedRuns:        []int{1},
+			expectedInterrupted: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cleanerClientset := newCleanerClientset(test.failCreates, test.conflictUpdates, test.failUpdates)
+			recorder := NewRecorder(cleanerClientset)
+			if test.maxDeletions != 0 {
+				recorder.maxDeletions = test.maxDeletions
+			}
+			for i := 0; i < test.deletions; i++ {
+				pvc := generators.GeneratePersistentVolumeClaim(fmt.Sprintf("pvc-test-sts-%v", i), constants.TEST_NAMESPACE, "test-sc", nil)
+				recorder.Record(context.TODO(), pvc, "dangling")
+			}
+			ctx, cancel := context.WithCancel(context.TODO())
+			if test.cancelled {
+				cancel()
+			}
+			defer cancel()
+			err := recorder.Complete(ctx)
+			if (err != nil) != (test.expectedUnrecorded != 0) || recorder.Unrecorded() != test.expectedUnrecorded {
+				t.Fatalf("Expected %v unrecorded deletions, got %v and error %v", test.expectedUnrecorded, recorder.Unrecorded(), err)
+			}
+
+			runs := listRuns(t, cleanerClientset)
+			if len(runs) != len(test.expectedRuns) {
+				t.Fatalf("Expected %v runs, got %v", len(test.expectedRuns), len(runs))
+			}
+			for i, expected := range test.expectedRuns {
+				name := fmt.Sprintf("pvc-cleanup-%v", i+1)
+				run := runs[name]
+				if len(run.Spec.Deletions) != expected {
+					t.Fatalf("Expected %v deletions in run %v, got %v", expected, name, len(run.Spec.Deletions))
+				}
+				last := i == len(test.expectedRuns)-1
+				if last != (run.Spec.ContinuedIn == "") {
+					t.Fatalf("Expected run %v to be continued %v, got %q", name, !last, run.Spec.ContinuedIn)
+				}
+				if last && (run.Spec.CompletionTime == nil || run.Spec.Interrupted != test.expectedInterrupted) {
+					t.Fatalf("Expected run %v completed, interrupted %v, got %+v", name, test.expectedInterrupted, run.Spec)
+				}
+			}
+		})
+	}
+}
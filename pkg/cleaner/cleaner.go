@@ -0,0 +1,59 @@
+// Package cleaner is the API for embedding the dangling StatefulSet PVC cleanup in other programs, such as operators.
+// It only depends on client interfaces, so it works with fake clientsets as well as real ones. Taking informer listers
+// instead of listing through the clientset is not supported yet, every Plan and Apply lists from the API server
+package cleaner
+
+import (
+	"context"
+
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/executor"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options configure a Cleaner, the provisioners are read from the PROVISIONERS environment variable
+type Options struct {
+	// CleanerClientset is used for PVCCleanupPolicies and PVCCleanupRuns. Without it PVCs are only selected
+	// by the StorageClass annotation and deletions are not recorded
+	CleanerClientset versioned.Interface
+	// DynamicClient is used for the VolumeSnapshots of the Snapshot action and the safety checks of the LVM and ZFS
+	// volumes. Without it those PVCs fail to be applied and are left in place
+	DynamicClient dynamic.Interface
+	// Namespaces to look for dangling PVCs in
+	Namespaces []string
+	// Limits of the deletions of Apply, the zero Limits delete one PVC at a time without any maximum
+	Limits limits.Limits
+}
+
+// Cleaner plans and applies the cleanup of dangling StatefulSet PVCs
+type Cleaner struct {
+	clientset kubernetes.Interface
+	options   Options
+}
+
+func New(clientset kubernetes.Interface, options Options) *Cleaner {
+	return &Cleaner{
+		clientset: clientset,
+		options:   options,
+	}
+}
+
+// Plan decides which StatefulSet PVCs are dangling and what would be done with them, without changing anything
+func (c *Cleaner) Plan(ctx context.Context) (*executor.CleanupPlan, error) {
+	decisions, err := executor.Plan(c.clientset, c.options.CleanerClientset, ctx, c.options.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+	if c.options.Limits.Force {
+		executor.Force(decisions)
+	}
+	return executor.NewCleanupPlan(decisions), nil
+}
+
+// Apply acts on the dangling PVCs of the plan, skipping those that changed since it was made.
+// An error is returned without applying anything if the plan goes over the maximum deletions of the limits
+func (c *Cleaner) Apply(ctx context.Context, plan *executor.CleanupPlan) (*executor.ApplyResult, error) {
+	return executor.Apply(c.clientset, c.options.CleanerClientset, c.options.DynamicClient, ctx, plan, c.options.Limits)
+}
@@ -0,0 +1,80 @@
+package cleaner
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	CoreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCleaner(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv(constants.PROVISIONERS_ENV_VAR, "openebs.io/local")
+	defer os.Unsetenv(constants.PROVISIONERS_ENV_VAR)
+
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	storageclass := generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, map[string]string{constants.STS_PVC_SELECTOR: "openebs.io/sts-pvc"}, "openebs.io/local")
+	statefulset := generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, selector, "test-sc")
+	mountedPVC := generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", selector)
+	mountedPVC.UID = "uid-0"
+	danglingPVC := generators.GeneratePersistentVolumeClaim("pvc-test-sts-1", constants.TEST_NAMESPACE, "test-sc", selector)
+	danglingPVC.UID = "uid-1"
+	pod := &CoreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sts-0", Namespace: constants.TEST_NAMESPACE, Labels: selector},
+		Spec: CoreV1.PodSpec{Volumes: []CoreV1.Volume{{
+			Name:         "pvc",
+			VolumeSource: CoreV1.VolumeSource{PersistentVolumeClaim: &CoreV1.PersistentVolumeClaimVolumeSource{ClaimName: mountedPVC.Name}},
+		}}},
+	}
+
+	clientset := fake.NewSimpleClientset(storageclass, statefulset, mountedPVC, danglingPVC, pod)
+	cleaner := New(clientset, Options{Namespaces: []string{constants.TEST_NAMESPACE}})
+	plan, err := cleaner.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		pvc            string
+		expectedAction string
+		expectDeleted  bool
+	}{
+		"PVC mounted by a StatefulSet pod is kept": {
+			pvc:            mountedPVC.Name,
+			expectedAction: "Keep",
+		},
+		"PVC not mounted by any pod is deleted": {
+			pvc:            danglingPVC.Name,
+			expectedAction: "Delete",
+			expectDeleted:  true,
+		},
+	}
+
+	result, err := cleaner.Apply(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var action string
+			for _, entry := range plan.Entries {
+				if entry.Name == test.pvc {
+					action = entry.Action
+				}
+			}
+			if action != test.expectedAction {
+				t.Fatalf("Expected action %v for PVC %v, got %q in %+v", test.expectedAction, test.pvc, action, plan.Entries)
+			}
+			_, err := clientset.CoreV1().PersistentVolumeClaims(constants.TEST_NAMESPACE).Get(ctx, test.pvc, metav1.GetOptions{})
+			if deleted := errors.IsNotFound(err); deleted != test.expectDeleted {
+				t.Fatalf("Expected PVC %v deleted to be %v, apply result %+v", test.pvc, test.expectDeleted, result.Entries)
+			}
+		})
+	}
+}
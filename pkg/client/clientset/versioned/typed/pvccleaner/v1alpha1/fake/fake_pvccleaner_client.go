@@ -0,0 +1,28 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/typed/pvccleaner/v1alpha1"
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+)
+
+type FakePVCCleanerV1alpha1 struct {
+	*testing.Fake
+}
+
+func (c *FakePVCCleanerV1alpha1) PVCCleanupPolicies() v1alpha1.PVCCleanupPolicyInterface {
+	return &FakePVCCleanupPolicies{c}
+}
+
+func (c *FakePVCCleanerV1alpha1) PVCCleanupRuns() v1alpha1.PVCCleanupRunInterface {
+	return &FakePVCCleanupRuns{c}
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakePVCCleanerV1alpha1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
@@ -0,0 +1,117 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakePVCCleanupPolicies implements PVCCleanupPolicyInterface
+type FakePVCCleanupPolicies struct {
+	Fake *FakePVCCleanerV1alpha1
+}
+
+var pvccleanuppoliciesResource = schema.GroupVersionResource{Group: "pvc-cleaner.openebs.io", Version: "v1alpha1", Resource: "pvccleanuppolicies"}
+
+var pvccleanuppoliciesKind = schema.GroupVersionKind{Group: "pvc-cleaner.openebs.io", Version: "v1alpha1", Kind: "PVCCleanupPolicy"}
+
+// Get takes name of the pVCCleanupPolicy, and returns the corresponding pVCCleanupPolicy object, and an error if there is any.
+func (c *FakePVCCleanupPolicies) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(pvccleanuppoliciesResource, name), &v1alpha1.PVCCleanupPolicy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupPolicy), err
+}
+
+// List takes label and field selectors, and returns the list of PVCCleanupPolicies that match those selectors.
+func (c *FakePVCCleanupPolicies) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PVCCleanupPolicyList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(pvccleanuppoliciesResource, pvccleanuppoliciesKind, opts), &v1alpha1.PVCCleanupPolicyList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.PVCCleanupPolicyList{ListMeta: obj.(*v1alpha1.PVCCleanupPolicyList).ListMeta}
+	for _, item := range obj.(*v1alpha1.PVCCleanupPolicyList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested pVCCleanupPolicies.
+func (c *FakePVCCleanupPolicies) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(pvccleanuppoliciesResource, opts))
+}
+
+// Create takes the representation of a pVCCleanupPolicy and creates it.  Returns the server's representation of the pVCCleanupPolicy, and an error, if there is any.
+func (c *FakePVCCleanupPolicies) Create(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.CreateOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(pvccleanuppoliciesResource, pVCCleanupPolicy), &v1alpha1.PVCCleanupPolicy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupPolicy), err
+}
+
+// Update takes the representation of a pVCCleanupPolicy and updates it. Returns the server's representation of the pVCCleanupPolicy, and an error, if there is any.
+func (c *FakePVCCleanupPolicies) Update(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(pvccleanuppoliciesResource, pVCCleanupPolicy), &v1alpha1.PVCCleanupPolicy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupPolicy), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakePVCCleanupPolicies) UpdateStatus(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (*v1alpha1.PVCCleanupPolicy, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(pvccleanuppoliciesResource, "status", pVCCleanupPolicy), &v1alpha1.PVCCleanupPolicy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupPolicy), err
+}
+
+// Delete takes name of the pVCCleanupPolicy and deletes it. Returns an error if one occurs.
+func (c *FakePVCCleanupPolicies) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(pvccleanuppoliciesResource, name), &v1alpha1.PVCCleanupPolicy{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePVCCleanupPolicies) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(pvccleanuppoliciesResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.PVCCleanupPolicyList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched pVCCleanupPolicy.
+func (c *FakePVCCleanupPolicies) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(pvccleanuppoliciesResource, name, pt, data, subresources...), &v1alpha1.PVCCleanupPolicy{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupPolicy), err
+}
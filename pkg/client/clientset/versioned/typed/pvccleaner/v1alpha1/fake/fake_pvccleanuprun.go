@@ -0,0 +1,106 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakePVCCleanupRuns implements PVCCleanupRunInterface
+type FakePVCCleanupRuns struct {
+	Fake *FakePVCCleanerV1alpha1
+}
+
+var pvccleanuprunsResource = schema.GroupVersionResource{Group: "pvc-cleaner.openebs.io", Version: "v1alpha1", Resource: "pvccleanupruns"}
+
+var pvccleanuprunsKind = schema.GroupVersionKind{Group: "pvc-cleaner.openebs.io", Version: "v1alpha1", Kind: "PVCCleanupRun"}
+
+// Get takes name of the pVCCleanupRun, and returns the corresponding pVCCleanupRun object, and an error if there is any.
+func (c *FakePVCCleanupRuns) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(pvccleanuprunsResource, name), &v1alpha1.PVCCleanupRun{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupRun), err
+}
+
+// List takes label and field selectors, and returns the list of PVCCleanupRuns that match those selectors.
+func (c *FakePVCCleanupRuns) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PVCCleanupRunList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(pvccleanuprunsResource, pvccleanuprunsKind, opts), &v1alpha1.PVCCleanupRunList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.PVCCleanupRunList{ListMeta: obj.(*v1alpha1.PVCCleanupRunList).ListMeta}
+	for _, item := range obj.(*v1alpha1.PVCCleanupRunList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested pVCCleanupRuns.
+func (c *FakePVCCleanupRuns) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(pvccleanuprunsResource, opts))
+}
+
+// Create takes the representation of a pVCCleanupRun and creates it.  Returns the server's representation of the pVCCleanupRun, and an error, if there is any.
+func (c *FakePVCCleanupRuns) Create(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.CreateOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(pvccleanuprunsResource, pVCCleanupRun), &v1alpha1.PVCCleanupRun{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupRun), err
+}
+
+// Update takes the representation of a pVCCleanupRun and updates it. Returns the server's representation of the pVCCleanupRun, and an error, if there is any.
+func (c *FakePVCCleanupRuns) Update(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.UpdateOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(pvccleanuprunsResource, pVCCleanupRun), &v1alpha1.PVCCleanupRun{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupRun), err
+}
+
+// Delete takes name of the pVCCleanupRun and deletes it. Returns an error if one occurs.
+func (c *FakePVCCleanupRuns) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(pvccleanuprunsResource, name), &v1alpha1.PVCCleanupRun{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePVCCleanupRuns) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(pvccleanuprunsResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.PVCCleanupRunList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched pVCCleanupRun.
+func (c *FakePVCCleanupRuns) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupRun, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(pvccleanuprunsResource, name, pt, data, subresources...), &v1alpha1.PVCCleanupRun{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PVCCleanupRun), err
+}
@@ -0,0 +1,7 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+type PVCCleanupPolicyExpansion interface{}
+
+type PVCCleanupRunExpansion interface{}
@@ -0,0 +1,78 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/scheme"
+	rest "k8s.io/client-go/rest"
+)
+
+type PVCCleanerV1alpha1Interface interface {
+	RESTClient() rest.Interface
+	PVCCleanupPoliciesGetter
+	PVCCleanupRunsGetter
+}
+
+// PVCCleanerV1alpha1Client is used to interact with features provided by the pvc-cleaner.openebs.io group.
+type PVCCleanerV1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *PVCCleanerV1alpha1Client) PVCCleanupPolicies() PVCCleanupPolicyInterface {
+	return newPVCCleanupPolicies(c)
+}
+
+func (c *PVCCleanerV1alpha1Client) PVCCleanupRuns() PVCCleanupRunInterface {
+	return newPVCCleanupRuns(c)
+}
+
+// NewForConfig creates a new PVCCleanerV1alpha1Client for the given config.
+func NewForConfig(c *rest.Config) (*PVCCleanerV1alpha1Client, error) {
+	config := *c
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, err
+	}
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &PVCCleanerV1alpha1Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new PVCCleanerV1alpha1Client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) *PVCCleanerV1alpha1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new PVCCleanerV1alpha1Client for the given RESTClient.
+func New(c rest.Interface) *PVCCleanerV1alpha1Client {
+	return &PVCCleanerV1alpha1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) error {
+	gv := v1alpha1.SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	return nil
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *PVCCleanerV1alpha1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
@@ -0,0 +1,168 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	scheme "github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PVCCleanupPoliciesGetter has a method to return a PVCCleanupPolicyInterface.
+// A group's client should implement this interface.
+type PVCCleanupPoliciesGetter interface {
+	PVCCleanupPolicies() PVCCleanupPolicyInterface
+}
+
+// PVCCleanupPolicyInterface has methods to work with PVCCleanupPolicy resources.
+type PVCCleanupPolicyInterface interface {
+	Create(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.CreateOptions) (*v1alpha1.PVCCleanupPolicy, error)
+	Update(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (*v1alpha1.PVCCleanupPolicy, error)
+	UpdateStatus(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (*v1alpha1.PVCCleanupPolicy, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.PVCCleanupPolicy, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.PVCCleanupPolicyList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupPolicy, err error)
+	PVCCleanupPolicyExpansion
+}
+
+// pVCCleanupPolicies implements PVCCleanupPolicyInterface
+type pVCCleanupPolicies struct {
+	client rest.Interface
+}
+
+// newPVCCleanupPolicies returns a PVCCleanupPolicies
+func newPVCCleanupPolicies(c *PVCCleanerV1alpha1Client) *pVCCleanupPolicies {
+	return &pVCCleanupPolicies{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the pVCCleanupPolicy, and returns the corresponding pVCCleanupPolicy object, and an error if there is any.
+func (c *pVCCleanupPolicies) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	result = &v1alpha1.PVCCleanupPolicy{}
+	err = c.client.Get().
+		Resource("pvccleanuppolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PVCCleanupPolicies that match those selectors.
+func (c *pVCCleanupPolicies) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PVCCleanupPolicyList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.PVCCleanupPolicyList{}
+	err = c.client.Get().
+		Resource("pvccleanuppolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested pVCCleanupPolicies.
+func (c *pVCCleanupPolicies) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("pvccleanuppolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a pVCCleanupPolicy and creates it.  Returns the server's representation of the pVCCleanupPolicy, and an error, if there is any.
+func (c *pVCCleanupPolicies) Create(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.CreateOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	result = &v1alpha1.PVCCleanupPolicy{}
+	err = c.client.Post().
+		Resource("pvccleanuppolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pVCCleanupPolicy).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a pVCCleanupPolicy and updates it. Returns the server's representation of the pVCCleanupPolicy, and an error, if there is any.
+func (c *pVCCleanupPolicies) Update(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	result = &v1alpha1.PVCCleanupPolicy{}
+	err = c.client.Put().
+		Resource("pvccleanuppolicies").
+		Name(pVCCleanupPolicy.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pVCCleanupPolicy).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *pVCCleanupPolicies) UpdateStatus(ctx context.Context, pVCCleanupPolicy *v1alpha1.PVCCleanupPolicy, opts v1.UpdateOptions) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	result = &v1alpha1.PVCCleanupPolicy{}
+	err = c.client.Put().
+		Resource("pvccleanuppolicies").
+		Name(pVCCleanupPolicy.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pVCCleanupPolicy).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the pVCCleanupPolicy and deletes it. Returns an error if one occurs.
+func (c *pVCCleanupPolicies) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("pvccleanuppolicies").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *pVCCleanupPolicies) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("pvccleanuppolicies").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched pVCCleanupPolicy.
+func (c *pVCCleanupPolicies) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupPolicy, err error) {
+	result = &v1alpha1.PVCCleanupPolicy{}
+	err = c.client.Patch(pt).
+		Resource("pvccleanuppolicies").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,152 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	scheme "github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PVCCleanupRunsGetter has a method to return a PVCCleanupRunInterface.
+// A group's client should implement this interface.
+type PVCCleanupRunsGetter interface {
+	PVCCleanupRuns() PVCCleanupRunInterface
+}
+
+// PVCCleanupRunInterface has methods to work with PVCCleanupRun resources.
+type PVCCleanupRunInterface interface {
+	Create(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.CreateOptions) (*v1alpha1.PVCCleanupRun, error)
+	Update(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.UpdateOptions) (*v1alpha1.PVCCleanupRun, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.PVCCleanupRun, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.PVCCleanupRunList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupRun, err error)
+	PVCCleanupRunExpansion
+}
+
+// pVCCleanupRuns implements PVCCleanupRunInterface
+type pVCCleanupRuns struct {
+	client rest.Interface
+}
+
+// newPVCCleanupRuns returns a PVCCleanupRuns
+func newPVCCleanupRuns(c *PVCCleanerV1alpha1Client) *pVCCleanupRuns {
+	return &pVCCleanupRuns{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the pVCCleanupRun, and returns the corresponding pVCCleanupRun object, and an error if there is any.
+func (c *pVCCleanupRuns) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	result = &v1alpha1.PVCCleanupRun{}
+	err = c.client.Get().
+		Resource("pvccleanupruns").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PVCCleanupRuns that match those selectors.
+func (c *pVCCleanupRuns) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PVCCleanupRunList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.PVCCleanupRunList{}
+	err = c.client.Get().
+		Resource("pvccleanupruns").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested pVCCleanupRuns.
+func (c *pVCCleanupRuns) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("pvccleanupruns").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a pVCCleanupRun and creates it.  Returns the server's representation of the pVCCleanupRun, and an error, if there is any.
+func (c *pVCCleanupRuns) Create(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.CreateOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	result = &v1alpha1.PVCCleanupRun{}
+	err = c.client.Post().
+		Resource("pvccleanupruns").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pVCCleanupRun).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a pVCCleanupRun and updates it. Returns the server's representation of the pVCCleanupRun, and an error, if there is any.
+func (c *pVCCleanupRuns) Update(ctx context.Context, pVCCleanupRun *v1alpha1.PVCCleanupRun, opts v1.UpdateOptions) (result *v1alpha1.PVCCleanupRun, err error) {
+	result = &v1alpha1.PVCCleanupRun{}
+	err = c.client.Put().
+		Resource("pvccleanupruns").
+		Name(pVCCleanupRun.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(pVCCleanupRun).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the pVCCleanupRun and deletes it. Returns an error if one occurs.
+func (c *pVCCleanupRuns) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("pvccleanupruns").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *pVCCleanupRuns) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("pvccleanupruns").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched pVCCleanupRun.
+func (c *pVCCleanupRuns) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PVCCleanupRun, err error) {
+	result = &v1alpha1.PVCCleanupRun{}
+	err = c.client.Patch(pt).
+		Resource("pvccleanupruns").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
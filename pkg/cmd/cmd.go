@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/controller"
+	"github.com/ksraj123/lister-sa/pkg/executor"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/printers"
+	"github.com/ksraj123/lister-sa/pkg/server"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Options are shared by all subcommands
+type Options struct {
+	Clientset        kubernetes.Interface
+	CleanerClientset versioned.Interface
+	DynamicClient    dynamic.Interface
+	Namespaces       []string
+	// Output is the format plans and reports are printed in, one of table, json or yaml
+	Output string
+	Out    io.Writer
+	// Limits of the deletions of clean and apply
+	Limits limits.Limits
+}
+
+// NewOptions creates the clients of all subcommands from the config
+func NewOptions(config *rest.Config, namespaces []string, output string, out io.Writer) (*Options, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cleanerClientset, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Options{
+		Clientset:        clientset,
+		CleanerClientset: cleanerClientset,
+		DynamicClient:    dynamicClient,
+		Namespaces:       namespaces,
+		Output:           output,
+		Out:              out,
+		Limits:           limits.Defaults(),
+	}, nil
+}
+
+// the list command prints the same entries as plan, with fewer columns in a table
+type pvcList struct {
+	*executor.CleanupPlan
+}
+
+func (l pvcList) PrintTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSTORAGECLASS\tVOLUME\tPOLICY")
+	for _, entry := range l.Entries {
+		policy := entry.Policy
+		if policy == "" {
+			policy = "<annotation>"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", entry.Namespace, entry.Name, entry.StorageClassName, entry.VolumeName, policy)
+	}
+	w.Flush()
+}
+
+// List prints the StatefulSet PVCs selected for cleanup
+func List(ctx context.Context, o *Options) error {
+	decisions, err := executor.Plan(o.Clientset, o.CleanerClientset, ctx, o.Namespaces)
+	if err != nil {
+		return err
+	}
+	return printers.Print(o.Out, o.Output, pvcList{executor.NewCleanupPlan(decisions)})
+}
+
+// Dangling prints only the StatefulSet PVCs selected for cleanup that are dangling
+func Dangling(ctx context.Context, o *Options) error {
+	decisions, err := executor.Plan(o.Clientset, o.CleanerClientset, ctx, o.Namespaces)
+	if err != nil {
+		return err
+	}
+	var dangling []executor.Decision
+	for _, decision := range decisions {
+		if decision.Dangling {
+			dangling = append(dangling, decision)
+		}
+	}
+	return printers.Print(o.Out, o.Output, pvcList{executor.NewCleanupPlan(dangling)})
+}
+
+// Plan prints the dangling status of the StatefulSet PVCs selected for cleanup and the action that clean would take,
+// the plan is also saved to planFile unless it is empty
+func Plan(ctx context.Context, o *Options, planFile string) error {
+	plan, err := newPlan(ctx, o)
+	if err != nil {
+		return err
+	}
+	if planFile != "" {
+		if err := executor.SavePlan(plan, planFile); err != nil {
+			return err
+		}
+	}
+	return printers.Print(o.Out, o.Output, plan)
+}
+
+func newPlan(ctx context.Context, o *Options) (*executor.CleanupPlan, error) {
+	decisions, err := executor.Plan(o.Clientset, o.CleanerClientset, ctx, o.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+	if o.Limits.Force {
+		executor.Force(decisions)
+	}
+	return executor.NewCleanupPlan(decisions), nil
+}
+
+// Apply acts on the dangling PVCs of the plan saved in planFile, skipping those that drifted since
+func Apply(ctx context.Context, o *Options, planFile string) error {
+	plan, err := executor.LoadPlan(planFile)
+	if err != nil {
+		return err
+	}
+	result, err := executor.Apply(o.Clientset, o.CleanerClientset, o.DynamicClient, ctx, plan, o.Limits)
+	if err != nil {
+		return err
+	}
+	return printers.Print(o.Out, o.Output, result)
+}
+
+// Clean acts on the dangling PVCs
+func Clean(ctx context.Context, o *Options) error {
+	return executor.Execute(o.Clientset, o.CleanerClientset, o.DynamicClient, ctx, o.Namespaces, o.Limits)
+}
+
+// RunOptions configure the run command
+type RunOptions struct {
+	// Schedule is a cron expression, Interval is used if it is empty
+	Schedule       string
+	Interval       time.Duration
+	Jitter         time.Duration
+	LeaderElection controller.LeaderElection
+	// HealthAddr is the address the health and readiness endpoints are served on, nothing is served if it is empty
+	HealthAddr string
+	// DebugAddr is the address /debug/plan and /debug/pprof are served on, nothing is served if it is empty
+	DebugAddr string
+	// ReadyWindow is how long ago the last successful run of the leader may be for it to be ready,
+	// twice the time between two runs plus the jitter if zero
+	ReadyWindow time.Duration
+	// LastUsedThreshold is how old the last-used annotation of a mounted PVC has to be before it is stamped again
+	LastUsedThreshold time.Duration
+}
+
+// Run cleans up on the schedule until ctx is done, only while leading if leader election is enabled. Each run first
+// stamps the last-used annotation on the mounted PVCs and records the lineage of the StatefulSet PVCs, errors of those
+// are logged and the cleanup still runs. With an interval the first cleanup runs right away, with a schedule at the
+// first scheduled time
+func Run(ctx context.Context, o *Options, runOptions RunOptions) error {
+	c := &controller.Controller{
+		Schedule:       cron.Every(runOptions.Interval),
+		RunOnStart:     true,
+		Jitter:         runOptions.Jitter,
+		LeaderElection: runOptions.LeaderElection,
+		Clientset:      o.Clientset,
+		Reconcile: func(ctx context.Context) error {
+			// the bookkeeping only refines later runs, failing it must not stop the cleanup
+			if stamped, err := executor.TrackLastUsed(o.Clientset, ctx, o.Namespaces, runOptions.LastUsedThreshold); err != nil {
+				fmt.Fprintf(o.Out, "Error while stamping the last used time of mounted PVCs, Error = %v\n", err.Error())
+			} else {
+				fmt.Fprintf(o.Out, "Stamped the last used time of %v mounted PVCs\n", stamped)
+			}
+			if recorded, err := executor.RecordLineage(o.Clientset, ctx, o.Namespaces); err != nil {
+				fmt.Fprintf(o.Out, "Error while recording the statefulset lineage of PVCs, Error = %v\n", err.Error())
+			} else {
+				fmt.Fprintf(o.Out, "Recorded the statefulset lineage of %v PVCs\n", recorded)
+			}
+			return Clean(ctx, o)
+		},
+		Reachable: func(ctx context.Context) error {
+			_, err := o.Clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{Limit: 1})
+			return err
+		},
+	}
+	if runOptions.Schedule != "" {
+		schedule, err := cron.ParseStandard(runOptions.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q, %v", runOptions.Schedule, err.Error())
+		}
+		c.Schedule = schedule
+		c.RunOnStart = false
+	}
+	readyWindow := runOptions.ReadyWindow
+	if readyWindow == 0 {
+		next := c.Schedule.Next(time.Now())
+		readyWindow = 2*c.Schedule.Next(next).Sub(next) + runOptions.Jitter
+	}
+	serverOptions := server.Options{
+		Ready: func() error {
+			return c.Ready(readyWindow)
+		},
+		Plan: func(ctx context.Context) (*executor.CleanupPlan, error) {
+			return newPlan(ctx, o)
+		},
+	}
+	if runOptions.HealthAddr != "" {
+		server.New(runOptions.HealthAddr, serverOptions).Start(ctx)
+	}
+	if runOptions.DebugAddr != "" {
+		server.NewDebug(runOptions.DebugAddr, serverOptions).Start(ctx)
+	}
+	return c.Run(ctx)
+}
+
+// Report prints the storage held by the dangling StatefulSet PVCs clean would delete, an empty report if no StorageClass
+// is selected
+func Report(ctx context.Context, o *Options) error {
+	decisions, err := executor.Plan(o.Clientset, o.CleanerClientset, ctx, o.Namespaces)
+	if err != nil && err != executor.ErrNoStorageClasses {
+		return err
+	}
+	if o.Limits.Force {
+		executor.Force(decisions)
+	}
+	return printers.Print(o.Out, o.Output, executor.NewCapacityReport(o.Clientset, ctx, decisions))
+}
+
+// Explain prints every rule that made the PVC, given as <namespace>/<name>, eligible or ineligible for cleanup
+func Explain(ctx context.Context, o *Options, pvc string) error {
+	parts := strings.Split(pvc, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected <namespace>/<pvc>, got %q", pvc)
+	}
+	explanations, err := executor.Explain(o.Clientset, o.CleanerClientset, ctx, parts[0], parts[1])
+	if err != nil {
+		return err
+	}
+	return printers.Print(o.Out, o.Output, explanations)
+}
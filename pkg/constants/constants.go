@@ -1,10 +1,45 @@
 package constants
 
+import "time"
+
+// FINISH_TIMEOUT bounds the API calls that finish up a run after it was stopped
+const FINISH_TIMEOUT = 30 * time.Second
+
+// MAX_RUN_DELETIONS caps the deletions recorded in one PVCCleanupRun, well below the size limit of an object
+const MAX_RUN_DELETIONS = 1000
+
 const (
-	TEST_NAMESPACE           = "default"
-	NAMESPACES_ENV_VAR       = "NAMESPACES"
-	PROVISIONERS_ENV_VAR     = "PROVISIONERS"
-	STORAGE_CLASS_ANNOTATION = "openebs.io/delete-dangling-pvc"
-	STS_PVC_SELECTOR         = "sts-pvc-selector"
-	OPENEBS_NAMESPACe        = "openebs"
+	TEST_NAMESPACE                    = "default"
+	NAMESPACES_ENV_VAR                = "NAMESPACES"
+	PROVISIONERS_ENV_VAR              = "PROVISIONERS"
+	REPORT_ENV_VAR                    = "REPORT"
+	DELETION_CONCURRENCY_ENV_VAR      = "DELETION_CONCURRENCY"
+	DELETION_QPS_ENV_VAR              = "DELETION_QPS"
+	DELETION_BURST_ENV_VAR            = "DELETION_BURST"
+	MAX_DELETIONS_ENV_VAR             = "MAX_DELETIONS"
+	MAX_DELETIONS_PERCENT_ENV_VAR     = "MAX_DELETIONS_PERCENT"
+	POD_NAMESPACE_ENV_VAR             = "POD_NAMESPACE"
+	LEASE_NAME                        = "stale-sts-pvc-cleaner"
+	STORAGE_CLASS_ANNOTATION          = "openebs.io/delete-dangling-pvc"
+	STS_PVC_SELECTOR                  = "sts-pvc-selector"
+	OPENEBS_NAMESPACe                 = "openebs"
+	DANGLING_SINCE_ANNOTATION         = "pvc-cleaner.openebs.io/dangling-since"
+	QUARANTINE_LABEL                  = "pvc-cleaner.openebs.io/quarantined"
+	RETENTION_POLICY_ANNOTATION       = "pvc-cleaner.openebs.io/pvc-retention-policy"
+	WHEN_DELETED_ANNOTATION           = "pvc-cleaner.openebs.io/when-deleted"
+	HONOR_RETENTION_POLICY_ANNOTATION = "pvc-cleaner.openebs.io/honor-retention-policy"
+	CLEANUP_RETAINED_ANNOTATION       = "pvc-cleaner.openebs.io/cleanup-retained-volumes"
+	RECOVER_LOST_NODES_ANNOTATION     = "pvc-cleaner.openebs.io/recover-lost-nodes"
+	UNSTICK_PENDING_ANNOTATION        = "pvc-cleaner.openebs.io/unstick-pending"
+	ORPHAN_ANNOTATION                 = "pvc-cleaner.openebs.io/delete-orphan-pvc-after-days"
+	PROTECTED_ANNOTATION              = "pvc-cleaner.openebs.io/protected"
+	HELM_RESOURCE_POLICY              = "helm.sh/resource-policy"
+	LAST_USED_ANNOTATION              = "pvc-cleaner.openebs.io/last-used"
+	OWNER_STATEFULSET_ANNOTATION      = "pvc-cleaner.openebs.io/owner-statefulset"
+	OWNER_STATEFULSET_UID_ANNOTATION  = "pvc-cleaner.openebs.io/owner-statefulset-uid"
+	SOURCE_PVC_UID_LABEL              = "pvc-cleaner.openebs.io/source-pvc-uid"
+	CLAIM_TEMPLATE_ANNOTATION         = "pvc-cleaner.openebs.io/claim-template"
+	HOSTPATH_PROVISIONER              = "openebs.io/local"
+	LVM_PROVISIONER                   = "local.csi.openebs.io"
+	ZFS_PROVISIONER                   = "zfs.csi.openebs.io"
 )
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// timings of the leader election, the tests shorten them
+var (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// LeaderElection configures the Lease the replicas of a controller elect the one that reconciles with
+type LeaderElection struct {
+	Enabled        bool
+	LeaseName      string
+	LeaseNamespace string
+	// Identity of the replica in the Lease, the hostname if empty which is the pod name in a Deployment
+	Identity string
+}
+
+// Controller reconciles on its Schedule until its context is done
+type Controller struct {
+	// Schedule is cron.Every for a fixed interval or a parsed cron expression
+	Schedule cron.Schedule
+	// RunOnStart reconciles right away too, instead of only at the first scheduled time
+	RunOnStart bool
+	// Jitter is the maximum random delay of each scheduled run, so that clusters sharing a schedule do not run at once
+	Jitter         time.Duration
+	LeaderElection LeaderElection
+	// Clientset is used for the Lease
+	Clientset kubernetes.Interface
+	// Reconcile is one cleanup run, its error is printed and the next run happens as scheduled
+	Reconcile func(ctx context.Context) error
+	// Reachable checks that the API server can be reached, it is retried until it succeeds before campaigning or
+	// reconciling and the controller is not ready until then
+	Reachable func(ctx context.Context) error
+
+	mu           sync.Mutex
+	reachable    bool
+	leadingSince time.Time
+	lastSuccess  time.Time
+	lastErr      error
+}
+
+// Ready returns why the controller is not ready: the API server was not reached yet, or it reconciles and had no
+// successful run within window. A replica that is not leading is ready once the API server was reached
+func (c *Controller) Ready(window time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.reachable {
+		return fmt.Errorf("API server not reachable yet")
+	}
+	if c.leadingSince.IsZero() {
+		return nil
+	}
+	since := c.leadingSince
+	if c.lastSuccess.After(since) {
+		since = c.lastSuccess
+	}
+	if time.Since(since) <= window {
+		return nil
+	}
+	if c.lastErr != nil {
+		return fmt.Errorf("no successful cleanup run in the last %v, last run failed, %v", window, c.lastErr.Error())
+	}
+	return fmt.Errorf("no successful cleanup run in the last %v", window)
+}
+
+// Run blocks until ctx is done. With leader election only the leader reconciles. Once ctx is done or leadership is lost
+// the leader waits for the running reconcile to finish, including the deletions it started, and only then releases the
+// Lease so that another replica takes over without waiting for the Lease to expire
+func (c *Controller) Run(ctx context.Context) error {
+	c.waitReachable(ctx)
+	if !c.LeaderElection.Enabled {
+		c.loop(ctx)
+		return nil
+	}
+
+	identity := c.LeaderElection.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("could not get hostname for the leader election identity, %v", err.Error())
+		}
+		identity = hostname
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      c.LeaderElection.LeaseName,
+			Namespace: c.LeaderElection.LeaseNamespace,
+		},
+		Client:     c.Clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	// campaign again until ctx is done, the elector returns once leadership is lost
+	for ctx.Err() == nil {
+		if err := c.lead(ctx, lock, identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// campaigns for the Lease and reconciles while leading. The elector gets its own context that is only cancelled once the
+// loop drained, so the Lease is renewed until then and released after
+func (c *Controller) lead(ctx context.Context, lock resourcelock.Interface, identity string) error {
+	electorCtx, stopElector := context.WithCancel(context.Background())
+	defer stopElector()
+	// buffered, so that the elector does not block if ctx is done before the loop is started
+	leading := make(chan context.Context, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				leading <- leaderCtx
+			},
+			OnStoppedLeading: func() {
+				fmt.Printf("%v stopped leading\n", identity)
+			},
+			OnNewLeader: func(leader string) {
+				fmt.Printf("Leader of Lease %v/%v is %v\n", c.LeaderElection.LeaseNamespace, c.LeaderElection.LeaseName, leader)
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		elector.Run(electorCtx)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case leaderCtx := <-leading:
+		// the loop stops once ctx is done or leadership is lost
+		loopCtx, stopLoop := context.WithCancel(leaderCtx)
+		go func() {
+			select {
+			case <-ctx.Done():
+				stopLoop()
+			case <-loopCtx.Done():
+			}
+		}()
+		c.loop(loopCtx)
+		stopLoop()
+	}
+	stopElector()
+	<-stopped
+	return nil
+}
+
+// reconciles on the schedule until ctx is done, which is also when leadership is lost. A scheduled run is skipped
+// if the previous one is still running, once ctx is done the running one is waited for
+func (c *Controller) loop(ctx context.Context) {
+	c.mu.Lock()
+	c.leadingSince = time.Now()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.leadingSince = time.Time{}
+		c.mu.Unlock()
+	}()
+
+	if c.RunOnStart {
+		c.reconcile(ctx)
+	}
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	scheduler := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.PrintfLogger(log.New(os.Stdout, "", 0)))))
+	scheduler.Schedule(c.Schedule, cron.FuncJob(func() {
+		if c.Jitter > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(random.Int63n(int64(c.Jitter)))):
+			}
+		}
+		c.reconcile(ctx)
+	}))
+	scheduler.Start()
+	<-ctx.Done()
+	<-scheduler.Stop().Done()
+}
+
+func (c *Controller) reconcile(ctx context.Context) {
+	err := c.Reconcile(ctx)
+	if err != nil {
+		fmt.Printf("Cleanup run failed, Error = %v\n", err.Error())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+	if err == nil {
+		c.lastSuccess = time.Now()
+	}
+}
+
+func (c *Controller) waitReachable(ctx context.Context) {
+	if c.Reachable != nil {
+		wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+			if err := c.Reachable(ctx); err != nil {
+				fmt.Printf("Could not reach the API server, Error = %v\n", err.Error())
+				return false, nil
+			}
+			return true, nil
+		}, ctx.Done())
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	c.mu.Lock()
+	c.reachable = true
+	c.mu.Unlock()
+}
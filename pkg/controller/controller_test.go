@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testLeaseName      = "stale-sts-pvc-cleaner"
+	testLeaseNamespace = "default"
+)
+
+func init() {
+	leaseDuration = time.Second
+	renewDeadline = 500 * time.Millisecond
+	retryPeriod = 100 * time.Millisecond
+}
+
+// every is a schedule shorter than the one second cron.Every rounds to
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func newLeaderController(clientset *fake.Clientset, identity string, reconcile func(ctx context.Context) error) *Controller {
+	return &Controller{
+		Schedule:   every(time.Hour),
+		RunOnStart: true,
+		LeaderElection: LeaderElection{
+			Enabled:        true,
+			LeaseName:      testLeaseName,
+			LeaseNamespace: testLeaseNamespace,
+			Identity:       identity,
+		},
+		Clientset: clientset,
+		Reconcile: reconcile,
+	}
+}
+
+func leaseHolder(t *testing.T, clientset *fake.Clientset) string {
+	lease, err := clientset.CoordinationV1().Leases(testLeaseNamespace).Get(context.TODO(), testLeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// waits until the condition holds or fails the test after a few lease durations
+func eventually(t *testing.T, condition func() bool, message string) {
+	deadline := time.Now().Add(5 * leaseDuration)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(retryPeriod / 2)
+	}
+}
+
+func TestOneLeaderReconciles(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	reconciled := make(map[string]int)
+	var wg sync.WaitGroup
+	for _, identity := range []string{"replica-a", "replica-b"} {
+		identity := identity
+		c := newLeaderController(clientset, identity, func(ctx context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			reconciled[identity]++
+			return nil
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(reconciled) != 0
+	}, "Expected a leader to reconcile")
+	// the other replica keeps campaigning for a few lease durations without taking over
+	time.Sleep(2 * leaseDuration)
+	lock.Lock()
+	if len(reconciled) != 1 {
+		t.Errorf("Expected only the leader to reconcile, got %v", reconciled)
+	}
+	for identity, runs := range reconciled {
+		if runs != 1 || leaseHolder(t, clientset) != identity {
+			t.Errorf("Expected leader %v holding the Lease to reconcile once, got %v runs and holder %v", identity, runs, leaseHolder(t, clientset))
+		}
+	}
+	lock.Unlock()
+	cancel()
+	wg.Wait()
+}
+
+func TestCancelledLeaderReleasesLeaseAfterReconcile(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	started, finished, releasedEarly := false, false, false
+	unblock := make(chan struct{})
+	clientset.PrependReactor("update", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lease := action.(k8stesting.UpdateAction).GetObject().(*coordinationv1.Lease)
+		lock.Lock()
+		defer lock.Unlock()
+		if started && !finished && (lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "") {
+			releasedEarly = true
+		}
+		return false, nil, nil
+	})
+	c := newLeaderController(clientset, "replica-a", func(ctx context.Context) error {
+		lock.Lock()
+		started = true
+		lock.Unlock()
+		<-unblock
+		lock.Lock()
+		finished = true
+		lock.Unlock()
+		return nil
+	})
+	done := make(chan error)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return started
+	}, "Expected the leader to reconcile")
+	cancel()
+	// the Lease is renewed past its duration while the reconcile runs
+	time.Sleep(2 * leaseDuration)
+	if holder := leaseHolder(t, clientset); holder != "replica-a" {
+		t.Fatalf("Expected the Lease to be held during the running reconcile, got holder %q", holder)
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if holder := leaseHolder(t, clientset); holder != "" || releasedEarly {
+		t.Fatalf("Expected the Lease to be released only after the reconcile finished, got holder %q, released early %v", holder, releasedEarly)
+	}
+}
+
+func TestLostLeaseStopsRuns(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	runs := 0
+	c := newLeaderController(clientset, "replica-a", func(ctx context.Context) error {
+		lock.Lock()
+		defer lock.Unlock()
+		runs++
+		return nil
+	})
+	c.Schedule = every(50 * time.Millisecond)
+	done := make(chan error)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+	eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return runs > 1
+	}, "Expected the leader to reconcile on its schedule")
+
+	// another replica takes the Lease over for longer than the test runs
+	lease, err := clientset.CoordinationV1().Leases(testLeaseNamespace).Get(context.TODO(), testLeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, duration, now := "replica-b", int32(3600), metav1.NowMicro()
+	lease.Spec.HolderIdentity, lease.Spec.LeaseDurationSeconds, lease.Spec.RenewTime = &other, &duration, &now
+	if _, err := clientset.CoordinationV1().Leases(testLeaseNamespace).Update(context.TODO(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// the renew deadline passes and the running reconcile, if any, finishes
+	time.Sleep(renewDeadline + 2*retryPeriod)
+	lock.Lock()
+	lost := runs
+	lock.Unlock()
+	time.Sleep(leaseDuration)
+	lock.Lock()
+	if runs != lost {
+		t.Errorf("Expected no runs once the Lease was lost, got %v more", runs-lost)
+	}
+	lock.Unlock()
+	if holder := leaseHolder(t, clientset); holder != other {
+		t.Errorf("Expected the Lease to stay with %v, got %v", other, holder)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduleSkipsOverlappingRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	runs, running, overlapped := 0, 0, false
+	unblock := make(chan struct{})
+	c := &Controller{
+		Schedule: every(20 * time.Millisecond),
+		Reconcile: func(ctx context.Context) error {
+			lock.Lock()
+			runs++
+			running++
+			overlapped = overlapped || running > 1
+			first := runs == 1
+			lock.Unlock()
+			// the first run blocks over several scheduled times
+			if first {
+				<-unblock
+			}
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return nil
+		},
+	}
+	done := make(chan error)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return runs == 1
+	}, "Expected the schedule to start a run")
+	time.Sleep(200 * time.Millisecond)
+	lock.Lock()
+	if runs != 1 {
+		t.Errorf("Expected the scheduled runs to be skipped while the first one runs, got %v runs", runs)
+	}
+	lock.Unlock()
+	close(unblock)
+	eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return runs > 2
+	}, "Expected the schedule to run again once the first run finished")
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if overlapped {
+		t.Fatalf("Expected runs never to overlap")
+	}
+}
@@ -2,59 +2,254 @@ package danglingpvcs
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/limits"
 	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Takes in Statefulset PVCs of deletion allowed storage classes as argument and returns a map containing dangling status of given PVCs.
-func GetStatusMap(clientset *kubernetes.Clientset, ctx context.Context, namespace string, statefulsetPvcs []v1.PersistentVolumeClaim) map[string]bool {
-
-	allStatefulsets := listers.ListAllStatefulSets(clientset, ctx, namespace)
+// The PVCs, StatefulSets and Pods of the namespace are listed here, the decision itself is taken by engine.DecideDangling
+func GetStatusMap(clientset kubernetes.Interface, ctx context.Context, namespace string, statefulsetPvcs []v1.PersistentVolumeClaim) map[string]bool {
 	pvcDanglingStatusList := make(map[string]bool)
-
-	// initally mark all openebs statefulset pvcs as dangling
-	for _, openebsPvc := range statefulsetPvcs {
-		pvcDanglingStatusList[openebsPvc.ObjectMeta.Name] = true
+	for _, decision := range GetDecisions(clientset, ctx, namespace, statefulsetPvcs) {
+		pvcDanglingStatusList[decision.PVC.Name] = decision.Dangling
 	}
+	return pvcDanglingStatusList
+}
 
-	// iterate over all pods in all statefulsets and mark the pvc they are bound to as not dagling
-	for _, statefulset := range allStatefulsets {
-		statefulsetLabels := statefulset.Spec.Selector.MatchLabels
-		labelSelectorString := labels.SelectorFromSet(statefulsetLabels).String()
+// GetDecisions is GetStatusMap with the reason of each decision
+func GetDecisions(clientset kubernetes.Interface, ctx context.Context, namespace string, statefulsetPvcs []v1.PersistentVolumeClaim) []engine.Decision {
+	snapshot := &engine.Snapshot{
+		PVCs:         listers.ListAllPersistentVolumeClaims(clientset, ctx, namespace),
+		StatefulSets: listers.ListAllStatefulSets(clientset, ctx, namespace),
+		Pods:         listers.ListAllPods(clientset, ctx, namespace),
+	}
+	return engine.DecideDangling(snapshot, statefulsetPvcs)
+}
 
-		// ToDo: Maybe this should be moved into listers
-		pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelectorString})
-		if err != nil {
-			fmt.Printf("Could not get Pods of label %v in namespace %v, Error = %v\n", labelSelectorString, namespace, err.Error())
+// Deletes the PVCs marked dangling in the status map with the deleter, recording each deletion with the recorder.
+// Returns the first error once all deletions are done
+func Delete(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, namespace string, statefulsetPvcs []v1.PersistentVolumeClaim, openebsPVCsStatus map[string]bool, recorder *audit.Recorder, deleter *limits.Deleter) error {
+	var firstErr error
+	var errLock sync.Mutex
+	for i := range statefulsetPvcs {
+		pvc := &statefulsetPvcs[i]
+		if openebsPVCsStatus[pvc.Name] {
+			fmt.Println(pvc.Name + " is dangling!")
+			reason := fmt.Sprintf("not mounted by any pod, storage class %v has annotation %v", *pvc.Spec.StorageClassName, constants.STORAGE_CLASS_ANNOTATION)
+			deleter.Go(ctx, func(ctx context.Context) {
+				if err := DeletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil {
+					errLock.Lock()
+					defer errLock.Unlock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("Error while deleting danling PVC %v in namespace %v, %v", pvc.Name, namespace, err.Error())
+					}
+				}
+			})
 		}
+	}
+	deleter.Wait()
+	return firstErr
+}
 
-		for _, pod := range pods.Items {
-			podVolumes := pod.Spec.Volumes
-			for _, volume := range podVolumes {
-				if volume.PersistentVolumeClaim != nil {
-					pvcDanglingStatusList[volume.PersistentVolumeClaim.ClaimName] = false
-				}
-			}
+// Deletes the PVC once the plugin of the provisioner of its volume passed it. If the volume is Retain and its StorageClass
+// has the cleanup-retained-volumes annotation set, the plugin cleans up the volume too
+func DeletePVC(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, reason string, recorder *audit.Recorder) error {
+	return deletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder, false)
+}
+
+// DeleteLostPVC deletes the PVC of a volume whose node was removed from the cluster like DeletePVC, and the plugin always
+// cleans up the volume, whatever its reclaim policy, as the provisioner can not reach the node to do it anymore
+func DeleteLostPVC(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, reason string, recorder *audit.Recorder) error {
+	return deletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder, true)
+}
+
+func deletePVC(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, reason string, recorder *audit.Recorder, lostNode bool) error {
+	pv, err := boundVolume(clientset, ctx, pvc)
+	if err != nil {
+		return err
+	}
+	var plugin provisioners.Plugin
+	if pv != nil {
+		plugin = provisioners.ForVolume(pv)
+		if err := plugin.SafetyCheck(ctx, dynamicClient, pv); err != nil {
+			return fmt.Errorf("safety check of provisioner %v failed, %v", plugin.Provisioner(), err.Error())
 		}
 	}
-	return pvcDanglingStatusList
+	// a PVC recreated under the same name since the decision is not deleted
+	err = clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(pvc.UID))})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dangling PVC %v in namesapce %v deleted successfully\n", pvc.Name, pvc.Namespace)
+	recorder.Record(ctx, pvc, reason)
+	if pv != nil && (lostNode || pv.Spec.PersistentVolumeReclaimPolicy == v1.PersistentVolumeReclaimRetain && cleanupRetained(clientset, ctx, pvc)) {
+		if err := plugin.Cleanup(ctx, clientset, dynamicClient, pv); err != nil {
+			fmt.Printf("Could not clean up volume %v of PVC %v in namespace %v, Error = %v\n", pv.Name, pvc.Name, pvc.Namespace, err.Error())
+		}
+	}
+	return nil
 }
 
-func Delete(clientset *kubernetes.Clientset, ctx context.Context, namespace string, openebsPVCsStatus map[string]bool) {
-	for pvcName, isDangling := range openebsPVCsStatus {
-		if isDangling {
-			fmt.Println(pvcName + " is dangling!")
-			err := clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, pvcName, metav1.DeleteOptions{})
-			if err == nil {
-				fmt.Printf("Dangling PVC %v in namesapce %v deleted successfully\n", pvcName, namespace)
-			} else {
-				panic(fmt.Sprintf("Error while deleting danling PVC %v in namespace %v", pvcName, namespace))
-			}
+// returns the volume the PVC is bound to, nil if it is not bound. A volume bound to another claim is an error
+func boundVolume(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	if pvc.Spec.VolumeName == "" {
+		return nil, nil
+	}
+	pv, err := clientset.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get volume %v, %v", pvc.Spec.VolumeName, err.Error())
+	}
+	if pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID {
+		return nil, fmt.Errorf("volume %v is bound to another claim %v/%v", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	}
+	return pv, nil
+}
+
+func cleanupRetained(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim) bool {
+	if pvc.Spec.StorageClassName == nil {
+		return false
+	}
+	storageclass, err := clientset.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		fmt.Printf("Could not get storage class %v, Error = %v\n", *pvc.Spec.StorageClassName, err.Error())
+		return false
+	}
+	return storageclass.Annotations[constants.CLEANUP_RETAINED_ANNOTATION] == "true"
+}
+
+// Returns the time since which the PVC has been dangling as found by a previous run, false if it was not
+func DanglingSince(pvc *v1.PersistentVolumeClaim) (time.Time, bool) {
+	value, exists := pvc.Annotations[constants.DANGLING_SINCE_ANNOTATION]
+	if !exists {
+		return time.Time{}, false
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	return since, err == nil
+}
+
+// Returns the time since which the PVC has been dangling, stamping the current time on the PVC if it was not found dangling before
+func MarkDangling(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, now time.Time) time.Time {
+	if value, exists := pvc.Annotations[constants.DANGLING_SINCE_ANNOTATION]; exists {
+		since, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			return since
 		}
+		fmt.Printf("Invalid %v annotation %v on PVC %v in namespace %v, resetting it\n", constants.DANGLING_SINCE_ANNOTATION, value, pvc.Name, pvc.Namespace)
+	}
+	err := patchMetadata(clientset, ctx, pvc, "annotations", constants.DANGLING_SINCE_ANNOTATION, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		fmt.Printf("Could not mark PVC %v in namespace %v as dangling, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+	}
+	return now
+}
+
+// Removes the dangling since annotation from a PVC that is mounted again
+func UnmarkDangling(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim) {
+	if _, exists := pvc.Annotations[constants.DANGLING_SINCE_ANNOTATION]; !exists {
+		return
+	}
+	err := patchMetadata(clientset, ctx, pvc, "annotations", constants.DANGLING_SINCE_ANNOTATION, nil)
+	if err != nil {
+		fmt.Printf("Could not unmark PVC %v in namespace %v as dangling, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+	}
+}
+
+// Returns the time the PVC was last seen mounted by a pod as stamped by StampLastUsed, false if it never was
+func LastUsed(pvc *v1.PersistentVolumeClaim) (time.Time, bool) {
+	value, exists := pvc.Annotations[constants.LAST_USED_ANNOTATION]
+	if !exists {
+		return time.Time{}, false
+	}
+	lastUsed, err := time.Parse(time.RFC3339, value)
+	return lastUsed, err == nil
+}
+
+// Stamps the current time as the last time the PVC was used, unless the stamp is less than threshold old. Returns if the
+// PVC was patched
+func StampLastUsed(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, now time.Time, threshold time.Duration) (bool, error) {
+	if lastUsed, found := LastUsed(pvc); found && now.Sub(lastUsed) < threshold {
+		return false, nil
+	}
+	if err := patchMetadata(clientset, ctx, pvc, "annotations", constants.LAST_USED_ANNOTATION, now.UTC().Format(time.RFC3339)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Records the whenDeleted retention policy of the StatefulSet of the PVC on it, so that it is still known once the
+// StatefulSet is deleted
+func RecordWhenDeleted(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, whenDeleted string) {
+	if pvc.Annotations[constants.WHEN_DELETED_ANNOTATION] == whenDeleted {
+		return
+	}
+	err := patchMetadata(clientset, ctx, pvc, "annotations", constants.WHEN_DELETED_ANNOTATION, whenDeleted)
+	if err != nil {
+		fmt.Printf("Could not record whenDeleted policy on PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+	}
+}
+
+// Records the lineage of the StatefulSet of the PVC on it in a single patch, unless the PVC already has that lineage
+func RecordLineage(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, lineage *engine.Lineage) (bool, error) {
+	if recorded := engine.PVCLineage(pvc); recorded != nil && *recorded == *lineage {
+		return false, nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				constants.OWNER_STATEFULSET_ANNOTATION:     lineage.StatefulSet,
+				constants.OWNER_STATEFULSET_UID_ANNOTATION: string(lineage.UID),
+				constants.CLAIM_TEMPLATE_ANNOTATION:        lineage.Template,
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	_, err = clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err == nil, err
+}
+
+// Labels the PVC as quarantined, returns false if the PVC already was
+func Quarantine(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Labels[constants.QUARANTINE_LABEL] == "true" {
+		return false, nil
+	}
+	err := patchMetadata(clientset, ctx, pvc, "labels", constants.QUARANTINE_LABEL, "true")
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Dangling PVC %v in namespace %v quarantined\n", pvc.Name, pvc.Namespace)
+	return true, nil
+}
+
+// sets a single label or annotation of the PVC with a merge patch, a nil value removes it
+func patchMetadata(clientset kubernetes.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, field string, key string, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			field: map[string]interface{}{key: value},
+		},
+	})
+	if err != nil {
+		return err
 	}
+	_, err = clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
 }
@@ -0,0 +1,190 @@
+// Package engine decides which StatefulSet PVCs are dangling from snapshots of the cluster objects, it makes no API calls
+// so that the decisions can be tested without a cluster
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	AppsV1 "k8s.io/api/apps/v1"
+	BatchV1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Below this many StatefulSet PVCs in a namespace SafetyCheck does not look at the dangling fraction
+const SafetyMinPVCs = 5
+
+// SafetyCheck fails a namespace with more than this percentage of its StatefulSet PVCs dangling
+const SafetyMaxDanglingPercent = 80
+
+// Snapshot holds the objects the decisions are taken on, they can be from any number of namespaces
+type Snapshot struct {
+	StorageClasses    []StorageV1.StorageClass
+	PVCs              []v1.PersistentVolumeClaim
+	PersistentVolumes []v1.PersistentVolume
+	StatefulSets      []AppsV1.StatefulSet
+	Pods              []v1.Pod
+	Nodes             []v1.Node
+	Events            []v1.Event
+	// Deployments, ReplicaSets, DaemonSets, Jobs and CronJobs are the workloads whose pod templates may reference a PVC
+	Deployments []AppsV1.Deployment
+	ReplicaSets []AppsV1.ReplicaSet
+	DaemonSets  []AppsV1.DaemonSet
+	Jobs        []BatchV1.Job
+	CronJobs    []BatchV1.CronJob
+	// ListErrors are the errors of the lists the snapshot was taken with, a failed list looks the same as an empty one
+	ListErrors []error
+}
+
+// Decision is the dangling status of a StatefulSet PVC and the reason for it
+type Decision struct {
+	PVC v1.PersistentVolumeClaim
+	// Volume is the PV the PVC is bound to, nil if it is unbound or the PV is not in the snapshot
+	Volume   *v1.PersistentVolume
+	Dangling bool
+	Reason   string
+	// WhenDeleted is the whenDeleted retention policy of the live StatefulSet of the PVC, empty if it has none
+	WhenDeleted AppsV1.PersistentVolumeClaimRetentionPolicyType
+	// DroppedTemplate is the volumeClaimTemplate the PVC was created from if it was dropped from its live StatefulSet,
+	// such a PVC is kept and reported instead
+	DroppedTemplate string
+	// Replica identifies the StatefulSet replica the PVC was created for, the claims of one replica are acted on together.
+	// Empty if it is unknown
+	Replica string
+}
+
+// Decide takes a decision on every StatefulSet PVC of a StorageClass of one of the provisioners that has the
+// delete-dangling-pvc annotation set, other PVCs are left out
+func Decide(snapshot *Snapshot, provisioners []string) []Decision {
+	storageClasses := make(map[string]*StorageV1.StorageClass)
+	for _, storageclass := range SelectedStorageClasses(snapshot.StorageClasses, provisioners) {
+		storageClasses[storageclass.Name] = storageclass
+	}
+	var statefulsetPvcs []v1.PersistentVolumeClaim
+	for _, pvc := range snapshot.PVCs {
+		if pvc.Spec.StorageClassName == nil {
+			continue
+		}
+		if storageclass, exists := storageClasses[*pvc.Spec.StorageClassName]; exists && IsStatefulSetPVC(&pvc, storageclass) {
+			statefulsetPvcs = append(statefulsetPvcs, pvc)
+		}
+	}
+	return DecideDangling(snapshot, statefulsetPvcs)
+}
+
+// DecideDangling takes a decision on each of the given StatefulSet PVCs, a PVC is dangling unless a pod of a StatefulSet
+// mounts it. The claims of one replica of a live StatefulSet are decided on together, none of them is dangling if the pod
+// mounts any of them. An unmounted replica in the range of its live StatefulSet is not dangling, out of the range the
+// retention policy of the StatefulSet decides if it has one
+func DecideDangling(snapshot *Snapshot, statefulsetPvcs []v1.PersistentVolumeClaim) []Decision {
+	mountingPods := MountingPods(snapshot.StatefulSets, snapshot.Pods)
+	volumes := make(map[string]*v1.PersistentVolume)
+	for i := range snapshot.PersistentVolumes {
+		volumes[snapshot.PersistentVolumes[i].Name] = &snapshot.PersistentVolumes[i]
+	}
+
+	var decisions []Decision
+	for _, pvc := range statefulsetPvcs {
+		decision := Decision{PVC: pvc, Volume: volumes[pvc.Spec.VolumeName], Dangling: true, Reason: "not mounted by any statefulset pod", Replica: Replica(&pvc, snapshot.StatefulSets)}
+		if statefulset, _ := ClaimOwner(&pvc, snapshot.StatefulSets); statefulset != nil {
+			if policy, _ := RetentionPolicy(statefulset); policy != nil {
+				decision.WhenDeleted = policy.WhenDeleted
+			}
+		}
+		if pods, mounted := mountingPods[pvc.Namespace+"/"+pvc.Name]; mounted {
+			decision.Dangling = false
+			decision.Reason = fmt.Sprintf("mounted by statefulset pod %v", strings.Join(pods, ","))
+		} else if claim, pods := mountedReplicaClaim(&pvc, snapshot.StatefulSets, mountingPods); claim != "" {
+			decision.Dangling = false
+			decision.Reason = fmt.Sprintf("claim %v of the same replica is mounted by statefulset pod %v", claim, strings.Join(pods, ","))
+		} else if statefulset, template := DroppedTemplate(&pvc, snapshot.StatefulSets); statefulset != nil {
+			decision.Dangling = false
+			decision.DroppedTemplate = template
+			decision.Reason = fmt.Sprintf("volumeClaimTemplate %v was dropped from statefulset %v", template, statefulset.Name)
+		} else {
+			decideUnmounted(&decision, snapshot.StatefulSets)
+		}
+		decisions = append(decisions, decision)
+	}
+	keepReplicas(snapshot, decisions)
+	return decisions
+}
+
+// MountingPods maps <namespace>/<claim> of every PVC mounted by pods selected by a StatefulSet to those pods as <statefulset>/<pod>
+func MountingPods(statefulsets []AppsV1.StatefulSet, pods []v1.Pod) map[string][]string {
+	mountingPods := make(map[string][]string)
+	for _, statefulset := range statefulsets {
+		selector := labels.SelectorFromSet(statefulset.Spec.Selector.MatchLabels)
+		for _, pod := range pods {
+			if pod.Namespace != statefulset.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			for _, volume := range pod.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil {
+					claim := pod.Namespace + "/" + volume.PersistentVolumeClaim.ClaimName
+					mountingPods[claim] = append(mountingPods[claim], statefulset.Name+"/"+pod.Name)
+				}
+			}
+		}
+	}
+	return mountingPods
+}
+
+// SelectedStorageClasses returns the StorageClasses of one of the provisioners that have the delete-dangling-pvc annotation set
+func SelectedStorageClasses(storageClasses []StorageV1.StorageClass, provisioners []string) []*StorageV1.StorageClass {
+	var selected []*StorageV1.StorageClass
+	for i := range storageClasses {
+		storageclass := &storageClasses[i]
+		if HasProvisioner(storageclass, provisioners) && storageclass.Annotations[constants.STORAGE_CLASS_ANNOTATION] == "true" {
+			selected = append(selected, storageclass)
+		}
+	}
+	return selected
+}
+
+func HasProvisioner(storageclass *StorageV1.StorageClass, provisioners []string) bool {
+	for _, provisioner := range provisioners {
+		if storageclass.Provisioner == provisioner {
+			return true
+		}
+	}
+	return false
+}
+
+// A PVC is a StatefulSet PVC if the lineage of its StatefulSet was recorded on it, or if it has the label named by the
+// sts-pvc-selector parameter of its StorageClass set to true, Kubernetes copies the StatefulSet selector onto the PVCs it creates
+func IsStatefulSetPVC(pvc *v1.PersistentVolumeClaim, storageclass *StorageV1.StorageClass) bool {
+	if PVCLineage(pvc) != nil {
+		return true
+	}
+	selector := storageclass.Parameters[constants.STS_PVC_SELECTOR]
+	return selector != "" && pvc.Labels[selector] == "true"
+}
+
+// SafetyCheck returns why the decisions taken on the snapshot of one namespace can not be trusted, empty if they can.
+// A failed list or a misconfigured sts-pvc-selector makes every PVC look dangling, so the decisions are not trusted if
+// a list failed, if there are no StatefulSets but many StatefulSet PVCs or if most of the PVCs are dangling
+func SafetyCheck(snapshot *Snapshot, decisions []Decision) string {
+	if len(snapshot.ListErrors) != 0 {
+		return fmt.Sprintf("listing failed, %v", snapshot.ListErrors[0].Error())
+	}
+	if len(decisions) < SafetyMinPVCs {
+		return ""
+	}
+	if len(snapshot.StatefulSets) == 0 {
+		return fmt.Sprintf("no statefulsets but %v statefulset PVCs", len(decisions))
+	}
+	dangling := 0
+	for _, decision := range decisions {
+		if decision.Dangling {
+			dangling++
+		}
+	}
+	if dangling*100 > SafetyMaxDanglingPercent*len(decisions) {
+		return fmt.Sprintf("%v of %v statefulset PVCs dangling, more than %v%%", dangling, len(decisions), SafetyMaxDanglingPercent)
+	}
+	return ""
+}
@@ -0,0 +1,871 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	AppsV1 "k8s.io/api/apps/v1"
+	BatchV1 "k8s.io/api/batch/v1"
+	CoreV1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func generatePod(name string, labels map[string]string, claimName string) CoreV1.Pod {
+	return CoreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: constants.TEST_NAMESPACE, Labels: labels},
+		Spec: CoreV1.PodSpec{Volumes: []CoreV1.Volume{{
+			Name:         "pvc",
+			VolumeSource: CoreV1.VolumeSource{PersistentVolumeClaim: &CoreV1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}},
+		}}},
+	}
+}
+
+func TestDecide(t *testing.T) {
+	provisioners := []string{"openebs.io/local"}
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	parameters := map[string]string{constants.STS_PVC_SELECTOR: "openebs.io/sts-pvc"}
+	annotations := map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}
+	storageclass := *generators.GenerateStorageClass("test-sc", annotations, parameters, "openebs.io/local")
+	statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, selector, "test-sc")
+	scaledDown := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 0, selector, "test-sc")
+	pvc := *generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", selector)
+	pvc.Spec.VolumeName = "pv-0"
+	pv := CoreV1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-0"}}
+
+	tests := map[string]struct {
+		snapshot         Snapshot
+		expectedDangling map[string]bool
+		expectedReason   string
+		expectVolume     bool
+	}{
+		"PVC mounted by a StatefulSet pod is not dangling": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{storageclass},
+				PVCs:           []CoreV1.PersistentVolumeClaim{pvc},
+				StatefulSets:   []AppsV1.StatefulSet{statefulset},
+				Pods:           []CoreV1.Pod{generatePod("test-sts-0", selector, pvc.Name)},
+			},
+			expectedDangling: map[string]bool{pvc.Name: false},
+			expectedReason:   "mounted by statefulset pod test-sts/test-sts-0",
+		},
+		"PVC of a deleted StatefulSet is dangling": {
+			snapshot: Snapshot{
+				StorageClasses:    []StorageV1.StorageClass{storageclass},
+				PVCs:              []CoreV1.PersistentVolumeClaim{pvc},
+				PersistentVolumes: []CoreV1.PersistentVolume{pv},
+				Pods:              []CoreV1.Pod{generatePod("test-sts-0", selector, pvc.Name)},
+			},
+			expectedDangling: map[string]bool{pvc.Name: true},
+			expectedReason:   "not mounted by any statefulset pod",
+			expectVolume:     true,
+		},
+		"PVC of a removed replica mounted by a pod of no StatefulSet is dangling": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{storageclass},
+				PVCs:           []CoreV1.PersistentVolumeClaim{pvc},
+				StatefulSets:   []AppsV1.StatefulSet{scaledDown},
+				Pods:           []CoreV1.Pod{generatePod("standalone", map[string]string{"role": "other"}, pvc.Name)},
+			},
+			expectedDangling: map[string]bool{pvc.Name: true},
+			expectedReason:   "replica 0 removed by a scale down of statefulset test-sts",
+		},
+		"PVC of a replica in range whose pod is missing is not dangling": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{storageclass},
+				PVCs:           []CoreV1.PersistentVolumeClaim{pvc},
+				StatefulSets:   []AppsV1.StatefulSet{statefulset},
+			},
+			expectedDangling: map[string]bool{pvc.Name: false},
+			expectedReason:   "replica 0 of statefulset test-sts is in range, its pod is missing",
+		},
+		"PVC without the selector label is left out": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{storageclass},
+				PVCs:           []CoreV1.PersistentVolumeClaim{*generators.GeneratePersistentVolumeClaim("standalone", constants.TEST_NAMESPACE, "test-sc", nil)},
+			},
+			expectedDangling: map[string]bool{},
+		},
+		"PVC of a StorageClass without the annotation is left out": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{*generators.GenerateStorageClass("test-sc", nil, parameters, "openebs.io/local")},
+				PVCs:           []CoreV1.PersistentVolumeClaim{pvc},
+			},
+			expectedDangling: map[string]bool{},
+		},
+		"PVC of a StorageClass of another provisioner is left out": {
+			snapshot: Snapshot{
+				StorageClasses: []StorageV1.StorageClass{*generators.GenerateStorageClass("test-sc", annotations, parameters, "example.com/other")},
+				PVCs:           []CoreV1.PersistentVolumeClaim{pvc},
+			},
+			expectedDangling: map[string]bool{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			decisions := Decide(&test.snapshot, provisioners)
+			if len(decisions) != len(test.expectedDangling) {
+				t.Fatalf("Expected %v decisions, got %+v", len(test.expectedDangling), decisions)
+			}
+			for _, decision := range decisions {
+				if decision.Dangling != test.expectedDangling[decision.PVC.Name] || decision.Reason != test.expectedReason {
+					t.Fatalf("Expected PVC %v dangling %v because %q, got %v because %q", decision.PVC.Name, test.expectedDangling[decision.PVC.Name], test.expectedReason, decision.Dangling, decision.Reason)
+				}
+				if (decision.Volume != nil) != test.expectVolume {
+					t.Fatalf("Expected volume of PVC %v to be found %v, got %+v", decision.PVC.Name, test.expectVolume, decision.Volume)
+				}
+			}
+		})
+	}
+}
+
+func TestSafetyCheck(t *testing.T) {
+	statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, map[string]string{"role": "test"}, "test-sc")
+	decisions := func(dangling int, mounted int) []Decision {
+		var decisions []Decision
+		for i := 0; i < dangling; i++ {
+			decisions = append(decisions, Decision{Dangling: true})
+		}
+		for i := 0; i < mounted; i++ {
+			decisions = append(decisions, Decision{Dangling: false})
+		}
+		return decisions
+	}
+
+	tests := map[string]struct {
+		snapshot    Snapshot
+		decisions   []Decision
+		expectBlock bool
+	}{
+		"Namespace with a few dangling PVCs passes": {
+			snapshot:  Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}},
+			decisions: decisions(2, 8),
+		},
+		"Namespace with less than the minimum PVCs passes even if all are dangling": {
+			snapshot:  Snapshot{},
+			decisions: decisions(SafetyMinPVCs-1, 0),
+		},
+		"Failed list fails": {
+			snapshot:    Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}, ListErrors: []error{errors.New("forbidden")}},
+			decisions:   decisions(1, 0),
+			expectBlock: true,
+		},
+		"No StatefulSets but many StatefulSet PVCs fails": {
+			snapshot:    Snapshot{},
+			decisions:   decisions(SafetyMinPVCs, 0),
+			expectBlock: true,
+		},
+		"Most PVCs dangling fails": {
+			snapshot:    Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}},
+			decisions:   decisions(9, 1),
+			expectBlock: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			blocked := SafetyCheck(&test.snapshot, test.decisions)
+			if (blocked != "") != test.expectBlock {
+				t.Fatalf("Expected safety check to fail %v, got %q", test.expectBlock, blocked)
+			}
+		})
+	}
+}
+
+func TestDecideRetentionPolicy(t *testing.T) {
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	withPolicy := func(replicas int32, whenDeleted, whenScaled AppsV1.PersistentVolumeClaimRetentionPolicyType) AppsV1.StatefulSet {
+		statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, replicas, selector, "test-sc")
+		statefulset.Spec.PersistentVolumeClaimRetentionPolicy = &AppsV1.StatefulSetPersistentVolumeClaimRetentionPolicy{WhenDeleted: whenDeleted, WhenScaled: whenScaled}
+		return statefulset
+	}
+	withAnnotation := func(replicas int32, annotation string) AppsV1.StatefulSet {
+		statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, replicas, selector, "test-sc")
+		statefulset.Annotations = map[string]string{constants.RETENTION_POLICY_ANNOTATION: annotation}
+		return statefulset
+	}
+	withStart := func(replicas int32, start int32) AppsV1.StatefulSet {
+		statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, replicas, selector, "test-sc")
+		statefulset.Spec.Ordinals = &AppsV1.StatefulSetOrdinals{Start: start}
+		return statefulset
+	}
+	pvc := func(name string, annotations map[string]string, owners ...metav1.OwnerReference) CoreV1.PersistentVolumeClaim {
+		pvc := *generators.GeneratePersistentVolumeClaim(name, constants.TEST_NAMESPACE, "test-sc", selector)
+		pvc.Annotations = annotations
+		pvc.OwnerReferences = owners
+		return pvc
+	}
+
+	tests := map[string]struct {
+		statefulsets        []AppsV1.StatefulSet
+		pvc                 CoreV1.PersistentVolumeClaim
+		expectedDangling    bool
+		expectedReason      string
+		expectedWhenDeleted AppsV1.PersistentVolumeClaimRetentionPolicyType
+	}{
+		"Unmounted claim of a live replica is kept": {
+			statefulsets:        []AppsV1.StatefulSet{withPolicy(2, "Delete", "Delete")},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedReason:      "replica 1 of statefulset test-sts is in range, its pod is missing",
+			expectedWhenDeleted: "Delete",
+		},
+		"Claim of a scaled down replica is dangling with whenScaled=Delete": {
+			statefulsets:        []AppsV1.StatefulSet{withPolicy(1, "Retain", "Delete")},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedDangling:    true,
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts, deleted by its whenScaled=Delete",
+			expectedWhenDeleted: "Retain",
+		},
+		"Claim of a scaled down replica is kept with whenScaled=Retain": {
+			statefulsets:        []AppsV1.StatefulSet{withPolicy(1, "Delete", "Retain")},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts, retained by its whenScaled=Retain",
+			expectedWhenDeleted: "Delete",
+		},
+		"Policy types that are not set are no opinion": {
+			statefulsets:        []AppsV1.StatefulSet{withAnnotation(1, "whenDeleted=Delete")},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedDangling:    true,
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts",
+			expectedWhenDeleted: "Delete",
+		},
+		"Field defaulted to Retain by the apiserver is no opinion": {
+			statefulsets:     []AppsV1.StatefulSet{withPolicy(1, "Retain", "Retain")},
+			pvc:              pvc("pvc-test-sts-1", nil),
+			expectedDangling: true,
+			expectedReason:   "replica 1 removed by a scale down of statefulset test-sts",
+		},
+		"Annotation is used when the field is defaulted to Retain": {
+			statefulsets: []AppsV1.StatefulSet{func() AppsV1.StatefulSet {
+				statefulset := withAnnotation(1, "whenDeleted=Delete,whenScaled=Delete")
+				statefulset.Spec.PersistentVolumeClaimRetentionPolicy = &AppsV1.StatefulSetPersistentVolumeClaimRetentionPolicy{WhenDeleted: "Retain", WhenScaled: "Retain"}
+				return statefulset
+			}()},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedDangling:    true,
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts, deleted by its whenScaled=Delete",
+			expectedWhenDeleted: "Delete",
+		},
+		"Field of Retain is honoured with the honor-retention-policy annotation": {
+			statefulsets: []AppsV1.StatefulSet{func() AppsV1.StatefulSet {
+				statefulset := withPolicy(1, "Retain", "Retain")
+				statefulset.Annotations = map[string]string{constants.HONOR_RETENTION_POLICY_ANNOTATION: "true"}
+				return statefulset
+			}()},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts, retained by its whenScaled=Retain",
+			expectedWhenDeleted: "Retain",
+		},
+		"Annotation is used when the field is not set": {
+			statefulsets:        []AppsV1.StatefulSet{withAnnotation(1, "whenDeleted=Delete,whenScaled=Delete")},
+			pvc:                 pvc("pvc-test-sts-1", nil),
+			expectedDangling:    true,
+			expectedReason:      "replica 1 removed by a scale down of statefulset test-sts, deleted by its whenScaled=Delete",
+			expectedWhenDeleted: "Delete",
+		},
+		"Invalid annotation keeps the claim": {
+			statefulsets:   []AppsV1.StatefulSet{withAnnotation(1, "whenScaled=Remove")},
+			pvc:            pvc("pvc-test-sts-1", nil),
+			expectedReason: `invalid retention policy of statefulset test-sts, unknown retention policy type "Remove", expected Retain or Delete`,
+		},
+		"Claim of a removed replica of a StatefulSet without a policy is dangling": {
+			statefulsets:     []AppsV1.StatefulSet{*generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, selector, "test-sc")},
+			pvc:              pvc("pvc-test-sts-1", nil),
+			expectedDangling: true,
+			expectedReason:   "replica 1 removed by a scale down of statefulset test-sts",
+		},
+		"Claim below the start ordinal is of a removed replica": {
+			statefulsets:     []AppsV1.StatefulSet{withStart(2, 1)},
+			pvc:              pvc("pvc-test-sts-0", nil),
+			expectedDangling: true,
+			expectedReason:   "replica 0 removed by a scale down of statefulset test-sts",
+		},
+		"Claim within the replicas from the start ordinal is in range": {
+			statefulsets:   []AppsV1.StatefulSet{withStart(2, 1)},
+			pvc:            pvc("pvc-test-sts-2", nil),
+			expectedReason: "replica 2 of statefulset test-sts is in range, its pod is missing",
+		},
+		"Claim of a deleted StatefulSet with a recorded whenDeleted=Retain is kept": {
+			pvc:            pvc("pvc-test-sts-0", map[string]string{constants.WHEN_DELETED_ANNOTATION: "Retain"}),
+			expectedReason: "statefulset deleted, retained by its whenDeleted=Retain",
+		},
+		"Claim of a deleted StatefulSet owned by it is dangling": {
+			pvc:              pvc("pvc-test-sts-0", nil, metav1.OwnerReference{Kind: "StatefulSet", Name: "test-sts"}),
+			expectedDangling: true,
+			expectedReason:   "statefulset deleted, deleted by its whenDeleted=Delete",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			snapshot := &Snapshot{StatefulSets: test.statefulsets}
+			decision := DecideDangling(snapshot, []CoreV1.PersistentVolumeClaim{test.pvc})[0]
+			if decision.Dangling != test.expectedDangling || decision.Reason != test.expectedReason {
+				t.Fatalf("Expected dangling %v because %q, got %v because %q", test.expectedDangling, test.expectedReason, decision.Dangling, decision.Reason)
+			}
+			if decision.WhenDeleted != test.expectedWhenDeleted {
+				t.Fatalf("Expected whenDeleted %q, got %q", test.expectedWhenDeleted, decision.WhenDeleted)
+			}
+		})
+	}
+}
+
+func TestDecideReplicaClaims(t *testing.T) {
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 2, selector, "test-sc")
+	wal := statefulset.Spec.VolumeClaimTemplates[0]
+	wal.Name = "wal"
+	statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, wal)
+	pvc := func(name string) CoreV1.PersistentVolumeClaim {
+		return *generators.GeneratePersistentVolumeClaim(name, constants.TEST_NAMESPACE, "test-sc", selector)
+	}
+
+	tests := map[string]struct {
+		snapshot         Snapshot
+		pvcs             []CoreV1.PersistentVolumeClaim
+		expectedDangling map[string]bool
+		expectedReasons  map[string]string
+	}{
+		"Claim of a replica whose pod mounts only the other claim is kept": {
+			snapshot: Snapshot{
+				StatefulSets: []AppsV1.StatefulSet{statefulset},
+				Pods:         []CoreV1.Pod{generatePod("test-sts-2", selector, "pvc-test-sts-2")},
+			},
+			pvcs:             []CoreV1.PersistentVolumeClaim{pvc("pvc-test-sts-2"), pvc("wal-test-sts-2")},
+			expectedDangling: map[string]bool{"pvc-test-sts-2": false, "wal-test-sts-2": false},
+			expectedReasons: map[string]string{
+				"pvc-test-sts-2": "mounted by statefulset pod test-sts/test-sts-2",
+				"wal-test-sts-2": "claim pvc-test-sts-2 of the same replica is mounted by statefulset pod test-sts/test-sts-2",
+			},
+		},
+		"Claims of a removed replica are dangling together": {
+			snapshot:         Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}},
+			pvcs:             []CoreV1.PersistentVolumeClaim{pvc("pvc-test-sts-2"), pvc("wal-test-sts-2")},
+			expectedDangling: map[string]bool{"pvc-test-sts-2": true, "wal-test-sts-2": true},
+			expectedReasons: map[string]string{
+				"pvc-test-sts-2": "replica 2 removed by a scale down of statefulset test-sts",
+				"wal-test-sts-2": "replica 2 removed by a scale down of statefulset test-sts",
+			},
+		},
+		"Claim of a replica is kept if the other claim exists but is not decided on": {
+			snapshot: Snapshot{
+				StatefulSets: []AppsV1.StatefulSet{statefulset},
+				PVCs:         []CoreV1.PersistentVolumeClaim{pvc("pvc-test-sts-2"), pvc("wal-test-sts-2")},
+			},
+			pvcs:             []CoreV1.PersistentVolumeClaim{pvc("pvc-test-sts-2")},
+			expectedDangling: map[string]bool{"pvc-test-sts-2": false},
+			expectedReasons:  map[string]string{"pvc-test-sts-2": "claim wal-test-sts-2 of the same replica is kept"},
+		},
+		"Claim of a template dropped from a live StatefulSet is kept and reported": {
+			snapshot:         Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}},
+			pvcs:             []CoreV1.PersistentVolumeClaim{pvc("logs-test-sts-0")},
+			expectedDangling: map[string]bool{"logs-test-sts-0": false},
+			expectedReasons:  map[string]string{"logs-test-sts-0": "volumeClaimTemplate logs was dropped from statefulset test-sts"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			decisions := DecideDangling(&test.snapshot, test.pvcs)
+			for _, decision := range decisions {
+				if decision.Dangling != test.expectedDangling[decision.PVC.Name] || decision.Reason != test.expectedReasons[decision.PVC.Name] {
+					t.Fatalf("Expected PVC %v dangling %v because %q, got %v because %q", decision.PVC.Name, test.expectedDangling[decision.PVC.Name], test.expectedReasons[decision.PVC.Name], decision.Dangling, decision.Reason)
+				}
+				if (decision.DroppedTemplate != "") != (decision.PVC.Name == "logs-test-sts-0") {
+					t.Fatalf("Expected only logs-test-sts-0 to be of a dropped template, got %q for %v", decision.DroppedTemplate, decision.PVC.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDecideRecreatedStatefulSet(t *testing.T) {
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	created := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	recreated := metav1.NewTime(created.Add(time.Hour))
+	statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, selector, "test-sc")
+	statefulset.UID = "new-uid"
+	statefulset.CreationTimestamp = recreated
+	pvc := func(name string, owners ...metav1.OwnerReference) CoreV1.PersistentVolumeClaim {
+		pvc := *generators.GeneratePersistentVolumeClaim(name, constants.TEST_NAMESPACE, "test-sc", selector)
+		pvc.CreationTimestamp = created
+		pvc.OwnerReferences = owners
+		return pvc
+	}
+
+	tests := map[string]struct {
+		pvc              CoreV1.PersistentVolumeClaim
+		expectedDangling bool
+		expectedReason   string
+	}{
+		"Claim older than the StatefulSet is reserved": {
+			pvc:            pvc("pvc-test-sts-0"),
+			expectedReason: "reserved for replica 0 of statefulset test-sts, which was recreated after the PVC",
+		},
+		"Claim owned by the deleted StatefulSet is reserved by the recreated one": {
+			pvc:            pvc("pvc-test-sts-0", metav1.OwnerReference{Kind: "StatefulSet", Name: "test-sts", UID: "old-uid"}),
+			expectedReason: "reserved for replica 0 of statefulset test-sts, which was recreated after the PVC",
+		},
+		"Claim of a replica the recreated StatefulSet does not have is dangling": {
+			pvc:              pvc("pvc-test-sts-1"),
+			expectedDangling: true,
+			expectedReason:   "replica 1 removed by a scale down of statefulset test-sts",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			snapshot := &Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset}}
+			decision := DecideDangling(snapshot, []CoreV1.PersistentVolumeClaim{test.pvc})[0]
+			if decision.Dangling != test.expectedDangling || decision.Reason != test.expectedReason {
+				t.Fatalf("Expected dangling %v because %q, got %v because %q", test.expectedDangling, test.expectedReason, decision.Dangling, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestDecideNodeLoss(t *testing.T) {
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	statefulset := func(recover string) AppsV1.StatefulSet {
+		statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 2, selector, "test-sc")
+		statefulset.Annotations = map[string]string{constants.RECOVER_LOST_NODES_ANNOTATION: recover}
+		return statefulset
+	}
+	pvc := *generators.GeneratePersistentVolumeClaim("pvc-test-sts-1", constants.TEST_NAMESPACE, "test-sc", selector)
+	pvc.Spec.VolumeName = "pv-1"
+	volume := CoreV1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1", Annotations: map[string]string{"pv.kubernetes.io/provisioned-by": constants.HOSTPATH_PROVISIONER}},
+		Spec: CoreV1.PersistentVolumeSpec{NodeAffinity: &CoreV1.VolumeNodeAffinity{Required: &CoreV1.NodeSelector{
+			NodeSelectorTerms: []CoreV1.NodeSelectorTerm{{MatchExpressions: []CoreV1.NodeSelectorRequirement{
+				{Key: "kubernetes.io/hostname", Operator: CoreV1.NodeSelectorOpIn, Values: []string{"node-1"}},
+			}}},
+		}}},
+	}
+	node := func(name string) CoreV1.Node {
+		return CoreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/hostname": name}}}
+	}
+	pod := func(phase CoreV1.PodPhase) CoreV1.Pod {
+		pod := generatePod("test-sts-1", selector, "pvc-test-sts-1")
+		pod.Status.Phase = phase
+		return pod
+	}
+
+	zonal := volume.DeepCopy()
+	zonal.Annotations = map[string]string{"pv.kubernetes.io/provisioned-by": "ebs.csi.aws.com"}
+	zonal.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Key = "topology.kubernetes.io/zone"
+
+	tests := map[string]struct {
+		snapshot     Snapshot
+		volume       *CoreV1.PersistentVolume
+		expectedLoss bool
+		expectedPods int
+	}{
+		"Zonal volume of a provisioner that is not configured": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}, Nodes: []CoreV1.Node{node("node-2")}},
+			volume:   zonal,
+		},
+		"Opted in replica pinned to a deleted node is recovered with its pending pod": {
+			snapshot:     Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("1")}, Nodes: []CoreV1.Node{node("node-2")}, Pods: []CoreV1.Pod{pod(CoreV1.PodPending)}},
+			expectedLoss: true,
+			expectedPods: 1,
+		},
+		"All replicas opted in": {
+			snapshot:     Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}, Nodes: []CoreV1.Node{node("node-2")}},
+			expectedLoss: true,
+		},
+		"Replica not opted in": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("0")}, Nodes: []CoreV1.Node{node("node-2")}},
+		},
+		"Node of the volume exists": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}, Nodes: []CoreV1.Node{node("node-1"), node("node-2")}},
+		},
+		"Running pod of the replica": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}, Nodes: []CoreV1.Node{node("node-2")}, Pods: []CoreV1.Pod{pod(CoreV1.PodRunning)}},
+		},
+		"Empty node list": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}},
+		},
+		"Failed list": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{statefulset("*")}, Nodes: []CoreV1.Node{node("node-2")}, ListErrors: []error{errors.New("forbidden")}},
+		},
+		"Replica out of the range of the StatefulSet": {
+			snapshot: Snapshot{StatefulSets: []AppsV1.StatefulSet{func() AppsV1.StatefulSet {
+				statefulset := statefulset("*")
+				replicas := int32(1)
+				statefulset.Spec.Replicas = &replicas
+				return statefulset
+			}()}, Nodes: []CoreV1.Node{node("node-2")}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.snapshot.PVCs = []CoreV1.PersistentVolumeClaim{pvc}
+			test.snapshot.PersistentVolumes = []CoreV1.PersistentVolume{volume}
+			if test.volume != nil {
+				test.snapshot.PersistentVolumes = []CoreV1.PersistentVolume{*test.volume}
+			}
+			losses := DecideNodeLoss(&test.snapshot, []string{constants.HOSTPATH_PROVISIONER}, time.Now())
+			if (len(losses) != 0) != test.expectedLoss {
+				t.Fatalf("Expected node loss %v, got %v", test.expectedLoss, losses)
+			}
+			if test.expectedLoss && len(losses[0].Pods) != test.expectedPods {
+				t.Fatalf("Expected %v pending pods, got %v", test.expectedPods, len(losses[0].Pods))
+			}
+		})
+	}
+}
+
+func TestDecideUnstickPending(t *testing.T) {
+	selector := map[string]string{"role": "test", "openebs.io/sts-pvc": "true"}
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	statefulset := func(unstick string) AppsV1.StatefulSet {
+		statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, selector, "test-sc")
+		statefulset.Annotations = map[string]string{constants.RECOVER_LOST_NODES_ANNOTATION: "*", constants.UNSTICK_PENDING_ANNOTATION: unstick}
+		return statefulset
+	}
+	pvc := *generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", selector)
+	pvc.Spec.VolumeName = "pv-0"
+	volume := CoreV1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-0", Annotations: map[string]string{"pv.kubernetes.io/provisioned-by": constants.HOSTPATH_PROVISIONER}},
+		Spec: CoreV1.PersistentVolumeSpec{NodeAffinity: &CoreV1.VolumeNodeAffinity{Required: &CoreV1.NodeSelector{
+			NodeSelectorTerms: []CoreV1.NodeSelectorTerm{{MatchExpressions: []CoreV1.NodeSelectorRequirement{
+				{Key: "kubernetes.io/hostname", Operator: CoreV1.NodeSelectorOpIn, Values: []string{"node-0"}},
+			}}},
+		}}},
+	}
+	node := func(unschedulable bool, ready CoreV1.ConditionStatus) CoreV1.Node {
+		return CoreV1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-0", Labels: map[string]string{"kubernetes.io/hostname": "node-0"}},
+			Spec:       CoreV1.NodeSpec{Unschedulable: unschedulable},
+			Status:     CoreV1.NodeStatus{Conditions: []CoreV1.NodeCondition{{Type: CoreV1.NodeReady, Status: ready}}},
+		}
+	}
+	pod := generatePod("test-sts-0", selector, "pvc-test-sts-0")
+	pod.UID = "pod-uid"
+	pod.Status.Phase = CoreV1.PodPending
+	pod.Status.Conditions = []CoreV1.PodCondition{{Type: CoreV1.PodScheduled, Status: CoreV1.ConditionFalse, LastTransitionTime: metav1.NewTime(now.Add(-3 * time.Hour))}}
+	event := CoreV1.Event{
+		InvolvedObject: CoreV1.ObjectReference{Kind: "Pod", Name: "test-sts-0", UID: "pod-uid"},
+		Reason:         "FailedScheduling",
+		Message:        "0/1 nodes are available: 1 node(s) had volume node affinity conflict.",
+	}
+
+	tests := map[string]struct {
+		statefulset     AppsV1.StatefulSet
+		node            CoreV1.Node
+		events          []CoreV1.Event
+		expectedUnstick *UnstickPolicy
+		expectedReason  string
+	}{
+		"Pod pending longer than pendingFor on a cordoned node": {
+			statefulset:     statefulset("pendingFor=2h"),
+			node:            node(true, CoreV1.ConditionTrue),
+			events:          []CoreV1.Event{event},
+			expectedUnstick: &UnstickPolicy{PendingFor: 2 * time.Hour},
+			expectedReason:  "pod test-sts-0 pending for more than 2h0m0s, volume pv-0 is pinned to unavailable node node-0",
+		},
+		"Pod pending on a NotReady node, with a snapshot": {
+			statefulset:     statefulset("pendingFor=2h,snapshot=true,volumeSnapshotClassName=csi-zfs"),
+			node:            node(false, CoreV1.ConditionUnknown),
+			events:          []CoreV1.Event{event},
+			expectedUnstick: &UnstickPolicy{PendingFor: 2 * time.Hour, Snapshot: true, VolumeSnapshotClassName: "csi-zfs"},
+			expectedReason:  "pod test-sts-0 pending for more than 2h0m0s, volume pv-0 is pinned to unavailable node node-0",
+		},
+		"Pod pending for less than pendingFor": {
+			statefulset: statefulset("pendingFor=4h"),
+			node:        node(true, CoreV1.ConditionTrue),
+			events:      []CoreV1.Event{event},
+		},
+		"Node is available": {
+			statefulset: statefulset("pendingFor=2h"),
+			node:        node(false, CoreV1.ConditionTrue),
+			events:      []CoreV1.Event{event},
+		},
+		"No FailedScheduling event for the volume node affinity": {
+			statefulset: statefulset("pendingFor=2h"),
+			node:        node(true, CoreV1.ConditionTrue),
+		},
+		"Invalid annotation": {
+			statefulset: statefulset("pendingFor=soon"),
+			node:        node(true, CoreV1.ConditionTrue),
+			events:      []CoreV1.Event{event},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			snapshot := &Snapshot{
+				PVCs:              []CoreV1.PersistentVolumeClaim{pvc},
+				PersistentVolumes: []CoreV1.PersistentVolume{volume},
+				StatefulSets:      []AppsV1.StatefulSet{test.statefulset},
+				Pods:              []CoreV1.Pod{pod},
+				Nodes:             []CoreV1.Node{test.node},
+				Events:            test.events,
+			}
+			losses := DecideNodeLoss(snapshot, []string{constants.HOSTPATH_PROVISIONER}, now)
+			if test.expectedUnstick == nil {
+				if len(losses) != 0 {
+					t.Fatalf("Expected no replica to unstick, got %v", losses[0].Reason)
+				}
+				return
+			}
+			if len(losses) != 1 || *losses[0].Unstick != *test.expectedUnstick || losses[0].Reason != test.expectedReason {
+				t.Fatalf("Expected replica unstuck by %+v because %q, got %+v", *test.expectedUnstick, test.expectedReason, losses)
+			}
+		})
+	}
+}
+
+func TestDecideOrphans(t *testing.T) {
+	storageclass := *generators.GenerateStorageClass("test-sc", map[string]string{constants.ORPHAN_ANNOTATION: "7"}, map[string]string{constants.STS_PVC_SELECTOR: "openebs.io/sts-pvc"}, "openebs.io/local")
+	pvc := func(annotations map[string]string, labels map[string]string) CoreV1.PersistentVolumeClaim {
+		pvc := *generators.GeneratePersistentVolumeClaim("data", constants.TEST_NAMESPACE, "test-sc", labels)
+		pvc.Annotations = annotations
+		return pvc
+	}
+	deployment := AppsV1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: constants.TEST_NAMESPACE}}
+	deployment.Spec.Template.Spec = generatePod("web", nil, "data").Spec
+	cronjob := BatchV1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: constants.TEST_NAMESPACE}}
+	cronjob.Spec.JobTemplate.Spec.Template.Spec = generatePod("backup", nil, "data").Spec
+
+	tests := map[string]struct {
+		snapshot         Snapshot
+		expectedDecided  bool
+		expectedDangling bool
+		expectedReason   string
+	}{
+		"PVC left behind by an uninstalled Deployment is an orphan": {
+			snapshot:         Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, nil)}},
+			expectedDecided:  true,
+			expectedDangling: true,
+			expectedReason:   "not mounted by any pod or referenced by any workload",
+		},
+		"PVC mounted by a pod": {
+			snapshot:        Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, nil)}, Pods: []CoreV1.Pod{generatePod("job-abcde", nil, "data")}},
+			expectedDecided: true,
+			expectedReason:  "mounted by pod job-abcde",
+		},
+		"PVC referenced by a Deployment scaled to zero": {
+			snapshot:        Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, nil)}, Deployments: []AppsV1.Deployment{deployment}},
+			expectedDecided: true,
+			expectedReason:  "referenced by the pod template of deployment/web",
+		},
+		"PVC referenced by a CronJob between runs": {
+			snapshot:        Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, nil)}, CronJobs: []BatchV1.CronJob{cronjob}},
+			expectedDecided: true,
+			expectedReason:  "referenced by the pod template of cronjob/backup",
+		},
+		"PVC kept by the Helm resource policy": {
+			snapshot:        Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(map[string]string{constants.HELM_RESOURCE_POLICY: "keep"}, nil)}},
+			expectedDecided: true,
+			expectedReason:  "protected by annotation helm.sh/resource-policy=keep",
+		},
+		"PVC protected by the cleaner annotation": {
+			snapshot:        Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(map[string]string{constants.PROTECTED_ANNOTATION: "true"}, nil)}},
+			expectedDecided: true,
+			expectedReason:  "protected by annotation pvc-cleaner.openebs.io/protected",
+		},
+		"StatefulSet PVC is left to the dangling decisions": {
+			snapshot: Snapshot{PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, map[string]string{"openebs.io/sts-pvc": "true"})}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.snapshot.StorageClasses = []StorageV1.StorageClass{storageclass}
+			decisions := DecideOrphans(&test.snapshot, []string{"openebs.io/local"})
+			if (len(decisions) != 0) != test.expectedDecided {
+				t.Fatalf("Expected decided %v, got %v decisions", test.expectedDecided, len(decisions))
+			}
+			if test.expectedDecided && (decisions[0].Dangling != test.expectedDangling || decisions[0].Reason != test.expectedReason) {
+				t.Fatalf("Expected orphan %v because %q, got %v because %q", test.expectedDangling, test.expectedReason, decisions[0].Dangling, decisions[0].Reason)
+			}
+		})
+	}
+
+	without := storageclass
+	without.Annotations = map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}
+	if decisions := DecideOrphans(&Snapshot{StorageClasses: []StorageV1.StorageClass{without}, PVCs: []CoreV1.PersistentVolumeClaim{pvc(nil, nil)}}, []string{"openebs.io/local"}); len(decisions) != 0 {
+		t.Fatalf("Expected no decisions without the %v annotation, got %v", constants.ORPHAN_ANNOTATION, len(decisions))
+	}
+}
+
+func TestDecideLineage(t *testing.T) {
+	statefulset := *generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, map[string]string{"role": "test"}, "test-sc")
+	statefulset.UID = "sts-uid"
+	// no sts-pvc-selector label, the PVC is only known to be a StatefulSet PVC by its lineage
+	pvc := func(name string, uid string, whenDeleted string) CoreV1.PersistentVolumeClaim {
+		pvc := *generators.GeneratePersistentVolumeClaim(name, constants.TEST_NAMESPACE, "test-sc", nil)
+		pvc.Annotations = map[string]string{
+			constants.OWNER_STATEFULSET_ANNOTATION:     "test-sts",
+			constants.OWNER_STATEFULSET_UID_ANNOTATION: uid,
+			constants.CLAIM_TEMPLATE_ANNOTATION:        "pvc",
+		}
+		if whenDeleted != "" {
+			pvc.Annotations[constants.WHEN_DELETED_ANNOTATION] = whenDeleted
+		}
+		return pvc
+	}
+
+	tests := map[string]struct {
+		statefulsets     []AppsV1.StatefulSet
+		pvc              CoreV1.PersistentVolumeClaim
+		expectedIgnored  bool
+		expectedDangling bool
+		expectedReason   string
+	}{
+		"Lineage the PVC is not named after is ignored": {
+			pvc:             pvc("data-other-0", "sts-uid", ""),
+			expectedIgnored: true,
+		},
+		"Lineage that does not match the owner reference is ignored": {
+			pvc: func() CoreV1.PersistentVolumeClaim {
+				pvc := pvc("pvc-test-sts-0", "sts-uid", "")
+				pvc.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "test-sts", UID: "other-uid"}}
+				return pvc
+			}(),
+			expectedIgnored: true,
+		},
+		"Deleted StatefulSet is confirmed by its UID": {
+			pvc:              pvc("pvc-test-sts-0", "sts-uid", ""),
+			expectedDangling: true,
+			expectedReason:   "statefulset test-sts with uid sts-uid deleted",
+		},
+		"Deleted StatefulSet with whenDeleted=Retain": {
+			pvc:            pvc("pvc-test-sts-0", "sts-uid", "Retain"),
+			expectedReason: "statefulset test-sts with uid sts-uid deleted, retained by its whenDeleted=Retain",
+		},
+		"Replica of a StatefulSet recreated with another UID is reserved": {
+			statefulsets:   []AppsV1.StatefulSet{statefulset},
+			pvc:            pvc("pvc-test-sts-0", "old-uid", ""),
+			expectedReason: "reserved for replica 0 of statefulset test-sts, which was recreated after the PVC",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			storageclass := *generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, map[string]string{constants.STS_PVC_SELECTOR: "openebs.io/sts-pvc"}, "openebs.io/local")
+			snapshot := &Snapshot{
+				StorageClasses: []StorageV1.StorageClass{storageclass},
+				PVCs:           []CoreV1.PersistentVolumeClaim{test.pvc},
+				StatefulSets:   test.statefulsets,
+			}
+			decisions := Decide(snapshot, []string{"openebs.io/local"})
+			if test.expectedIgnored {
+				if len(decisions) != 0 {
+					t.Fatalf("Expected the PVC not to be selected by its lineage, got %v because %q", decisions[0].Dangling, decisions[0].Reason)
+				}
+				return
+			}
+			if len(decisions) != 1 {
+				t.Fatalf("Expected the PVC to be selected by its lineage, got %v decisions", len(decisions))
+			}
+			if decisions[0].Dangling != test.expectedDangling || decisions[0].Reason != test.expectedReason {
+				t.Fatalf("Expected dangling %v because %q, got %v because %q", test.expectedDangling, test.expectedReason, decisions[0].Dangling, decisions[0].Reason)
+			}
+		})
+	}
+
+	lineage := LineageOf(&CoreV1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-test-sts-0", Namespace: constants.TEST_NAMESPACE}}, []AppsV1.StatefulSet{statefulset})
+	if lineage == nil || *lineage != (Lineage{StatefulSet: "test-sts", UID: "sts-uid", Template: "pvc"}) {
+		t.Fatalf("Expected the lineage of replica 0 of test-sts, got %+v", lineage)
+	}
+	if lineage := LineageOf(&CoreV1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-test-sts-1", Namespace: constants.TEST_NAMESPACE}}, []AppsV1.StatefulSet{statefulset}); lineage != nil {
+		t.Fatalf("Expected no lineage for a replica out of the range, got %+v", lineage)
+	}
+	// the claims of a replica share it whether they are known from the live StatefulSet or from the lineage
+	live := generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	recorded := pvc("pvc-test-sts-0", "sts-uid", "")
+	if replica := Replica(live, []AppsV1.StatefulSet{statefulset}); replica == "" || replica != Replica(&recorded, nil) {
+		t.Fatalf("Expected the same replica from the statefulset and the lineage, got %q and %q", replica, Replica(&recorded, nil))
+	}
+	// without either the claims of a replica share the statefulset name parsed from their names
+	data := generators.GeneratePersistentVolumeClaim("data-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	wal := generators.GeneratePersistentVolumeClaim("wal-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	if replica := Replica(data, nil); replica != constants.TEST_NAMESPACE+"/test-sts/0" || replica != Replica(wal, nil) {
+		t.Fatalf("Expected the same replica parsed from the names, got %q and %q", replica, Replica(wal, nil))
+	}
+	if replica := Replica(&CoreV1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-0"}}, nil); replica != "" {
+		t.Fatalf("Expected no replica for a PVC not named after a statefulset, got %q", replica)
+	}
+}
+
+func TestSafetyCheckNodeLoss(t *testing.T) {
+	volume := func(node string) CoreV1.PersistentVolume {
+		return CoreV1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + node, Annotations: map[string]string{"pv.kubernetes.io/provisioned-by": constants.HOSTPATH_PROVISIONER}},
+			Spec: CoreV1.PersistentVolumeSpec{NodeAffinity: &CoreV1.VolumeNodeAffinity{Required: &CoreV1.NodeSelector{
+				NodeSelectorTerms: []CoreV1.NodeSelectorTerm{{MatchExpressions: []CoreV1.NodeSelectorRequirement{
+					{Key: "kubernetes.io/hostname", Operator: CoreV1.NodeSelectorOpIn, Values: []string{node}},
+				}}},
+			}}},
+		}
+	}
+	node := CoreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0", Labels: map[string]string{"kubernetes.io/hostname": "node-0"}}}
+	other := CoreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	var volumes []CoreV1.PersistentVolume
+	for i := 0; i < SafetyMinPVCs; i++ {
+		volumes = append(volumes, volume(fmt.Sprintf("node-%v", i)))
+	}
+
+	tests := map[string]struct {
+		snapshot    Snapshot
+		expectBlock bool
+	}{
+		"Some nodes of local volumes lost": {
+			snapshot: Snapshot{PersistentVolumes: volumes, Nodes: []CoreV1.Node{node}},
+		},
+		"All nodes of local volumes lost": {
+			snapshot:    Snapshot{PersistentVolumes: volumes, Nodes: []CoreV1.Node{other}},
+			expectBlock: true,
+		},
+		"Few nodes of local volumes": {
+			snapshot: Snapshot{PersistentVolumes: volumes[:1], Nodes: []CoreV1.Node{other}},
+		},
+		"Failed list": {
+			snapshot:    Snapshot{PersistentVolumes: volumes, Nodes: []CoreV1.Node{node}, ListErrors: []error{errors.New("forbidden")}},
+			expectBlock: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			blocked := SafetyCheckNodeLoss(&test.snapshot, []string{constants.HOSTPATH_PROVISIONER})
+			if (blocked != "") != test.expectBlock {
+				t.Fatalf("Expected safety check to fail %v, got %q", test.expectBlock, blocked)
+			}
+		})
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := map[string]struct {
+		name            string
+		expectedOrdinal int
+		expectedFound   bool
+	}{
+		"Ordinal zero":               {name: "pvc-test-sts-0", expectedOrdinal: 0, expectedFound: true},
+		"Ordinal of several digits":  {name: "pvc-test-sts-12", expectedOrdinal: 12, expectedFound: true},
+		"Leading zero is no ordinal": {name: "pvc-test-sts-01"},
+		"Zeros only are no ordinal":  {name: "pvc-test-sts-00"},
+		"Plus sign is no ordinal":    {name: "pvc-test-sts-+1"},
+		"Name without dash":          {name: "pvc"},
+		"Name without ordinal":       {name: "pvc-test-sts-"},
+		"Name ending in a word":      {name: "pvc-test-sts"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ordinal, found := Ordinal(test.name)
+			if found != test.expectedFound || (found && ordinal != test.expectedOrdinal) {
+				t.Fatalf("Expected ordinal %v found %v, got %v found %v", test.expectedOrdinal, test.expectedFound, ordinal, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	AppsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Lineage is the StatefulSet a PVC was created for and its volumeClaimTemplate, recorded on the PVC in the lineage
+// annotations while the StatefulSet is alive
+type Lineage struct {
+	StatefulSet string
+	UID         types.UID
+	Template    string
+}
+
+// PVCLineage returns the lineage recorded on the PVC, nil if it has none. The annotations can be set by anyone, so a
+// lineage the PVC is not named after as <template>-<statefulset>-<ordinal>, or that does not match the StatefulSet owner
+// reference of the PVC, is ignored
+func PVCLineage(pvc *v1.PersistentVolumeClaim) *Lineage {
+	lineage := &Lineage{
+		StatefulSet: pvc.Annotations[constants.OWNER_STATEFULSET_ANNOTATION],
+		UID:         types.UID(pvc.Annotations[constants.OWNER_STATEFULSET_UID_ANNOTATION]),
+		Template:    pvc.Annotations[constants.CLAIM_TEMPLATE_ANNOTATION],
+	}
+	if lineage.StatefulSet == "" || lineage.UID == "" || lineage.Template == "" {
+		return nil
+	}
+	prefix := lineage.Template + "-" + lineage.StatefulSet + "-"
+	if _, found := Ordinal(pvc.Name); !found || !strings.HasPrefix(pvc.Name, prefix) || strings.Contains(pvc.Name[len(prefix):], "-") {
+		return nil
+	}
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" && (owner.Name != lineage.StatefulSet || owner.UID != lineage.UID) {
+			return nil
+		}
+	}
+	return lineage
+}
+
+// LineageOf returns the lineage to record on the PVC, the live StatefulSet that reserves it for one of its replicas.
+// A PVC of a replica out of the range is left with the lineage it has, as it may be of an earlier StatefulSet of the
+// same name. Nil if no live StatefulSet reserves the PVC
+func LineageOf(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet) *Lineage {
+	statefulset, _ := ReservedBy(pvc, statefulsets)
+	if statefulset == nil {
+		return nil
+	}
+	for _, template := range statefulset.Spec.VolumeClaimTemplates {
+		if strings.HasPrefix(pvc.Name, template.Name+"-"+statefulset.Name+"-") {
+			return &Lineage{StatefulSet: statefulset.Name, UID: statefulset.UID, Template: template.Name}
+		}
+	}
+	return nil
+}
+
+// LineageOwner returns the live StatefulSet of the UID of the lineage, nil if it no longer exists
+func LineageOwner(lineage *Lineage, statefulsets []AppsV1.StatefulSet) *AppsV1.StatefulSet {
+	for i := range statefulsets {
+		if statefulsets[i].UID == lineage.UID {
+			return &statefulsets[i]
+		}
+	}
+	return nil
+}
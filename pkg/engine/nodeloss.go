@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+	AppsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// NodeLoss is a replica of a live StatefulSet whose PVC is bound to a local PV that no available node of the cluster can
+// satisfy, its pod can only be scheduled again with a fresh volume
+type NodeLoss struct {
+	PVC         v1.PersistentVolumeClaim
+	Volume      *v1.PersistentVolume
+	StatefulSet *AppsV1.StatefulSet
+	Ordinal     int
+	// Pods are the pending pods of the replica, they hold on to the PVC until they are deleted
+	Pods []v1.Pod
+	// Unstick is the unstick-pending policy the replica is recycled by if the node of its volume is unavailable, nil if
+	// the node no longer exists
+	Unstick *UnstickPolicy
+	Reason  string
+}
+
+// UnstickPolicy is the unstick-pending annotation of a StatefulSet
+type UnstickPolicy struct {
+	// PendingFor is how long the pod of a replica has to be pending before it is unstuck
+	PendingFor time.Duration
+	// Snapshot takes a VolumeSnapshot of the PVC, of the VolumeSnapshotClassName if it is set, and recycles the PVC once
+	// the snapshot is ready to use
+	Snapshot                bool
+	VolumeSnapshotClassName string
+}
+
+// DecideNodeLoss finds the PVCs of the snapshot of replicas that opted in with the recover-lost-nodes annotation of their
+// StatefulSet whose PV has a required node affinity that none of the nodes of the snapshot matches. Only local PVs of the
+// given provisioners are looked at, PVs whose plugin pins them to a single node, a zonal PV is never lost with a node. If the StatefulSet
+// also has the unstick-pending annotation, PVCs whose PV only matches cordoned or NotReady nodes are found too once the
+// pod of the replica has been pending for long enough and failed scheduling because of the node affinity of the volume.
+// A failed list or an empty node list would make every node look lost, so nothing is decided on then. A replica with a
+// running pod is left alone
+func DecideNodeLoss(snapshot *Snapshot, configured []string, now time.Time) []NodeLoss {
+	if len(snapshot.ListErrors) != 0 || len(snapshot.Nodes) == 0 {
+		return nil
+	}
+	volumes := make(map[string]*v1.PersistentVolume)
+	for i := range snapshot.PersistentVolumes {
+		volumes[snapshot.PersistentVolumes[i].Name] = &snapshot.PersistentVolumes[i]
+	}
+
+	var losses []NodeLoss
+	for _, pvc := range snapshot.PVCs {
+		statefulset, ordinal := ReservedBy(&pvc, snapshot.StatefulSets)
+		if statefulset == nil || !RecoversLostNodes(statefulset, ordinal) || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		volume, exists := volumes[pvc.Spec.VolumeName]
+		if !exists || !LocalVolume(volume, configured) {
+			continue
+		}
+		loss := NodeLoss{PVC: pvc, Volume: volume, StatefulSet: statefulset, Ordinal: ordinal}
+		running := false
+		for _, pod := range snapshot.Pods {
+			if pod.Namespace != pvc.Namespace || pod.Name != fmt.Sprintf("%v-%v", statefulset.Name, ordinal) {
+				continue
+			}
+			if pod.Status.Phase == v1.PodPending {
+				loss.Pods = append(loss.Pods, pod)
+			} else if pod.Status.Phase == v1.PodRunning {
+				running = true
+			}
+		}
+		if running {
+			continue
+		}
+
+		nodes := MatchingNodes(volume, snapshot.Nodes)
+		if len(nodes) == 0 {
+			loss.Reason = fmt.Sprintf("volume %v of replica %v of statefulset %v is pinned to a node that no longer exists", volume.Name, ordinal, statefulset.Name)
+			losses = append(losses, loss)
+			continue
+		}
+		policy, err := ParseUnstickPolicy(statefulset)
+		if err != nil || policy == nil {
+			continue
+		}
+		for _, node := range nodes {
+			if NodeAvailable(node) {
+				policy = nil
+			}
+		}
+		if policy == nil {
+			continue
+		}
+		for _, pod := range loss.Pods {
+			if now.Sub(PendingSince(&pod)) >= policy.PendingFor && failedVolumeNodeAffinity(&pod, snapshot.Events) {
+				loss.Unstick = policy
+				loss.Reason = fmt.Sprintf("pod %v pending for more than %v, volume %v is pinned to unavailable node %v", pod.Name, policy.PendingFor, volume.Name, nodes[0].Name)
+			}
+		}
+		if loss.Unstick != nil {
+			losses = append(losses, loss)
+		}
+	}
+	return losses
+}
+
+// LocalVolume is true if the PV was provisioned by one of the given provisioners and its plugin finds the node it is
+// pinned to
+func LocalVolume(pv *v1.PersistentVolume, configured []string) bool {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+	plugin := provisioners.ForVolume(pv)
+	for _, provisioner := range configured {
+		if plugin.Provisioner() == provisioner {
+			return plugin.Node(pv) != ""
+		}
+	}
+	return false
+}
+
+// SafetyCheckNodeLoss fails if more than SafetyMaxDanglingPercent of the nodes the local PVs of the snapshot are pinned to
+// look lost, as a wrong node list looks the same as lost nodes. Below SafetyMinPVCs nodes it does not look at the fraction
+func SafetyCheckNodeLoss(snapshot *Snapshot, configured []string) string {
+	if len(snapshot.ListErrors) != 0 {
+		return fmt.Sprintf("listing failed, %v", snapshot.ListErrors[0].Error())
+	}
+	pinned := make(map[string]bool)
+	for i := range snapshot.PersistentVolumes {
+		volume := &snapshot.PersistentVolumes[i]
+		if !LocalVolume(volume, configured) {
+			continue
+		}
+		node := provisioners.ForVolume(volume).Node(volume)
+		pinned[node] = pinned[node] || len(MatchingNodes(volume, snapshot.Nodes)) == 0
+	}
+	if len(pinned) < SafetyMinPVCs {
+		return ""
+	}
+	lost := 0
+	for _, isLost := range pinned {
+		if isLost {
+			lost++
+		}
+	}
+	if lost*100 > SafetyMaxDanglingPercent*len(pinned) {
+		return fmt.Sprintf("%v of %v nodes of local volumes lost, more than %v%%", lost, len(pinned), SafetyMaxDanglingPercent)
+	}
+	return ""
+}
+
+// RecoversLostNodes is true if the recover-lost-nodes annotation of the StatefulSet is * or lists the ordinal, separated by commas
+func RecoversLostNodes(statefulset *AppsV1.StatefulSet, ordinal int) bool {
+	for _, value := range strings.Split(statefulset.Annotations[constants.RECOVER_LOST_NODES_ANNOTATION], ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || value == strconv.Itoa(ordinal) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseUnstickPolicy parses the pendingFor=<duration>,snapshot=<true|false>,volumeSnapshotClassName=<name> unstick-pending
+// annotation of the StatefulSet, pendingFor is required. Nil if the StatefulSet does not have the annotation
+func ParseUnstickPolicy(statefulset *AppsV1.StatefulSet) (*UnstickPolicy, error) {
+	annotation, found := statefulset.Annotations[constants.UNSTICK_PENDING_ANNOTATION]
+	if !found {
+		return nil, nil
+	}
+	policy := &UnstickPolicy{}
+	for _, pair := range strings.Split(annotation, ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("expected pendingFor=<duration>,snapshot=<true|false>,volumeSnapshotClassName=<name>, got %q", annotation)
+		}
+		var err error
+		switch keyValue[0] {
+		case "pendingFor":
+			policy.PendingFor, err = time.ParseDuration(keyValue[1])
+		case "snapshot":
+			policy.Snapshot, err = strconv.ParseBool(keyValue[1])
+		case "volumeSnapshotClassName":
+			policy.VolumeSnapshotClassName = keyValue[1]
+		default:
+			err = fmt.Errorf("unknown key %q", keyValue[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid unstick-pending annotation %q, %v", annotation, err.Error())
+		}
+	}
+	if policy.PendingFor <= 0 {
+		return nil, fmt.Errorf("invalid unstick-pending annotation %q, pendingFor has to be positive", annotation)
+	}
+	return policy, nil
+}
+
+// MatchingNodes returns the nodes that satisfy a term of the required node affinity of the PV. Only the In and Exists
+// operators on labels are matched, a term with any other requirement is taken to match so that the PV is kept
+func MatchingNodes(pv *v1.PersistentVolume, nodes []v1.Node) []*v1.Node {
+	var matching []*v1.Node
+	for i := range nodes {
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			if matchesTerm(term, &nodes[i]) {
+				matching = append(matching, &nodes[i])
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// NodeAvailable is false if the node is cordoned or not Ready
+func NodeAvailable(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PendingSince is the time the pod failed to be scheduled, its creation time if it has no PodScheduled condition
+func PendingSince(pod *v1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// the scheduler reports "node(s) had volume node affinity conflict" in the FailedScheduling events of the pod
+func failedVolumeNodeAffinity(pod *v1.Pod, events []v1.Event) bool {
+	for _, event := range events {
+		if event.Reason == "FailedScheduling" && event.InvolvedObject.Kind == "Pod" && event.InvolvedObject.Name == pod.Name &&
+			event.InvolvedObject.UID == pod.UID && strings.Contains(event.Message, "volume node affinity") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesTerm(term v1.NodeSelectorTerm, node *v1.Node) bool {
+	if len(term.MatchFields) != 0 {
+		return true
+	}
+	for _, expression := range term.MatchExpressions {
+		value, exists := node.Labels[expression.Key]
+		switch expression.Operator {
+		case v1.NodeSelectorOpIn:
+			found := false
+			for _, expected := range expression.Values {
+				found = found || (exists && value == expected)
+			}
+			if !found {
+				return false
+			}
+		case v1.NodeSelectorOpExists:
+			if !exists {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+	return true
+}
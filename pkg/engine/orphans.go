@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+)
+
+// DecideOrphans takes a decision on every PVC that is not a StatefulSet PVC, of a StorageClass of one of the provisioners
+// that has the delete-orphan-pvc-after-days annotation set. Such a PVC is an orphan, marked dangling, unless a pod mounts
+// it, the pod template of a workload references it or it is protected
+func DecideOrphans(snapshot *Snapshot, provisioners []string) []Decision {
+	storageClasses := make(map[string]*StorageV1.StorageClass)
+	for i := range snapshot.StorageClasses {
+		storageclass := &snapshot.StorageClasses[i]
+		if _, enabled := OrphanAfter(storageclass); enabled && HasProvisioner(storageclass, provisioners) {
+			storageClasses[storageclass.Name] = storageclass
+		}
+	}
+
+	var decisions []Decision
+	for _, pvc := range snapshot.PVCs {
+		if pvc.Spec.StorageClassName == nil || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		storageclass, exists := storageClasses[*pvc.Spec.StorageClassName]
+		if !exists || statefulsetClaim(&pvc, storageclass, snapshot) {
+			continue
+		}
+		decision := Decision{PVC: pvc, Dangling: true, Reason: "not mounted by any pod or referenced by any workload"}
+		if pods := mountingAnyPod(&pvc, snapshot.Pods); len(pods) != 0 {
+			decision.Dangling = false
+			decision.Reason = fmt.Sprintf("mounted by pod %v", strings.Join(pods, ","))
+		} else if workload := ReferencingWorkload(&pvc, snapshot); workload != "" {
+			decision.Dangling = false
+			decision.Reason = fmt.Sprintf("referenced by the pod template of %v", workload)
+		} else if protection := Protection(&pvc); protection != "" {
+			decision.Dangling = false
+			decision.Reason = fmt.Sprintf("protected by %v", protection)
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
+
+// OrphanAfter is how long a PVC of the StorageClass has to stay an orphan before it is deleted, in whole days set by the
+// delete-orphan-pvc-after-days annotation. False if the annotation is not set to a positive number
+func OrphanAfter(storageclass *StorageV1.StorageClass) (time.Duration, bool) {
+	days, err := strconv.Atoi(storageclass.Annotations[constants.ORPHAN_ANNOTATION])
+	if err != nil || days < 1 {
+		return 0, false
+	}
+	return time.Duration(days) * 24 * time.Hour, true
+}
+
+// ReferencingWorkload returns the first workload of the snapshot in the namespace of the PVC whose pod template has a
+// volume of the PVC, as <kind>/<name>. Empty if there is none
+func ReferencingWorkload(pvc *v1.PersistentVolumeClaim, snapshot *Snapshot) string {
+	type template struct {
+		namespace string
+		workload  string
+		spec      *v1.PodSpec
+	}
+	var templates []template
+	for i := range snapshot.StatefulSets {
+		templates = append(templates, template{snapshot.StatefulSets[i].Namespace, "statefulset/" + snapshot.StatefulSets[i].Name, &snapshot.StatefulSets[i].Spec.Template.Spec})
+	}
+	for i := range snapshot.Deployments {
+		templates = append(templates, template{snapshot.Deployments[i].Namespace, "deployment/" + snapshot.Deployments[i].Name, &snapshot.Deployments[i].Spec.Template.Spec})
+	}
+	for i := range snapshot.ReplicaSets {
+		templates = append(templates, template{snapshot.ReplicaSets[i].Namespace, "replicaset/" + snapshot.ReplicaSets[i].Name, &snapshot.ReplicaSets[i].Spec.Template.Spec})
+	}
+	for i := range snapshot.DaemonSets {
+		templates = append(templates, template{snapshot.DaemonSets[i].Namespace, "daemonset/" + snapshot.DaemonSets[i].Name, &snapshot.DaemonSets[i].Spec.Template.Spec})
+	}
+	for i := range snapshot.Jobs {
+		templates = append(templates, template{snapshot.Jobs[i].Namespace, "job/" + snapshot.Jobs[i].Name, &snapshot.Jobs[i].Spec.Template.Spec})
+	}
+	for i := range snapshot.CronJobs {
+		templates = append(templates, template{snapshot.CronJobs[i].Namespace, "cronjob/" + snapshot.CronJobs[i].Name, &snapshot.CronJobs[i].Spec.JobTemplate.Spec.Template.Spec})
+	}
+	for _, template := range templates {
+		if template.namespace == pvc.Namespace && claimsVolume(template.spec, pvc.Name) {
+			return template.workload
+		}
+	}
+	return ""
+}
+
+// Protection returns what protects the PVC from being deleted as an orphan: the protected annotation of the cleaner, the
+// keep resource policy of Helm or the quarantine label. Empty if nothing does
+func Protection(pvc *v1.PersistentVolumeClaim) string {
+	if pvc.Annotations[constants.PROTECTED_ANNOTATION] == "true" {
+		return fmt.Sprintf("annotation %v", constants.PROTECTED_ANNOTATION)
+	}
+	if pvc.Annotations[constants.HELM_RESOURCE_POLICY] == "keep" {
+		return fmt.Sprintf("annotation %v=keep", constants.HELM_RESOURCE_POLICY)
+	}
+	if pvc.Labels[constants.QUARANTINE_LABEL] == "true" {
+		return fmt.Sprintf("label %v", constants.QUARANTINE_LABEL)
+	}
+	return ""
+}
+
+// StatefulSet PVCs are left to DecideDangling, also the ones of deleted StatefulSets that the cleaner recorded a
+// whenDeleted policy on
+func statefulsetClaim(pvc *v1.PersistentVolumeClaim, storageclass *StorageV1.StorageClass, snapshot *Snapshot) bool {
+	if IsStatefulSetPVC(pvc, storageclass) || pvc.Annotations[constants.WHEN_DELETED_ANNOTATION] != "" {
+		return true
+	}
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return true
+		}
+	}
+	statefulset, _ := ClaimOwner(pvc, snapshot.StatefulSets)
+	return statefulset != nil
+}
+
+// pods of any phase count, the pods of a finished Job hold on to its PVC until the Job is deleted
+func mountingAnyPod(pvc *v1.PersistentVolumeClaim, pods []v1.Pod) []string {
+	var mounting []string
+	for _, pod := range pods {
+		if pod.Namespace == pvc.Namespace && claimsVolume(&pod.Spec, pvc.Name) {
+			mounting = append(mounting, pod.Name)
+		}
+	}
+	return mounting
+}
+
+func claimsVolume(spec *v1.PodSpec, claimName string) bool {
+	for _, volume := range spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+			return true
+		}
+	}
+	return false
+}
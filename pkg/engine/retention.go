@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	AppsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RetentionPolicy is the retention policy of the StatefulSet the cleaner honours. Since Kubernetes 1.27 the apiserver sets
+// the persistentVolumeClaimRetentionPolicy field of every StatefulSet to Retain for both, so a field that is Retain for
+// both is only honoured if the StatefulSet has the honor-retention-policy annotation set to true. Otherwise the
+// pvc-retention-policy annotation is used, as on clusters with the StatefulSetAutoDeletePVC feature gate off. A type that
+// is not set is empty, the cleaner has no opinion on it then. Nil if the StatefulSet has neither
+func RetentionPolicy(statefulset *AppsV1.StatefulSet) (*AppsV1.StatefulSetPersistentVolumeClaimRetentionPolicy, error) {
+	if field := statefulset.Spec.PersistentVolumeClaimRetentionPolicy; field != nil && (!retainDefaults(field) || statefulset.Annotations[constants.HONOR_RETENTION_POLICY_ANNOTATION] == "true") {
+		policy := *field
+		return &policy, nil
+	}
+	annotation, found := statefulset.Annotations[constants.RETENTION_POLICY_ANNOTATION]
+	if !found {
+		return nil, nil
+	}
+	policy := AppsV1.StatefulSetPersistentVolumeClaimRetentionPolicy{}
+	for _, pair := range strings.Split(annotation, ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("expected whenDeleted=<Retain|Delete>,whenScaled=<Retain|Delete>, got %q", annotation)
+		}
+		policyType, err := retentionPolicyType(keyValue[1])
+		if err != nil {
+			return nil, err
+		}
+		switch keyValue[0] {
+		case "whenDeleted":
+			policy.WhenDeleted = policyType
+		case "whenScaled":
+			policy.WhenScaled = policyType
+		default:
+			return nil, fmt.Errorf("unknown retention policy key %q", keyValue[0])
+		}
+	}
+	return &policy, nil
+}
+
+// the apiserver defaults both types to Retain, such a field can not be told apart from one set by the user
+func retainDefaults(policy *AppsV1.StatefulSetPersistentVolumeClaimRetentionPolicy) bool {
+	retained := func(policyType AppsV1.PersistentVolumeClaimRetentionPolicyType) bool {
+		return policyType == "" || policyType == AppsV1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	return retained(policy.WhenDeleted) && retained(policy.WhenScaled)
+}
+
+func retentionPolicyType(value string) (AppsV1.PersistentVolumeClaimRetentionPolicyType, error) {
+	switch policyType := AppsV1.PersistentVolumeClaimRetentionPolicyType(strings.TrimSpace(value)); policyType {
+	case AppsV1.RetainPersistentVolumeClaimRetentionPolicyType, AppsV1.DeletePersistentVolumeClaimRetentionPolicyType:
+		return policyType, nil
+	}
+	return "", fmt.Errorf("unknown retention policy type %q, expected Retain or Delete", value)
+}
+
+// ClaimOwner returns the StatefulSet among the given ones that the PVC was created for from one of its volumeClaimTemplates,
+// and the ordinal of the replica it was created for. Nil if it is not a claim of any of them
+func ClaimOwner(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet) (*AppsV1.StatefulSet, int) {
+	for i := range statefulsets {
+		statefulset := &statefulsets[i]
+		if statefulset.Namespace != pvc.Namespace {
+			continue
+		}
+		for _, template := range statefulset.Spec.VolumeClaimTemplates {
+			prefix := template.Name + "-" + statefulset.Name + "-"
+			if !strings.HasPrefix(pvc.Name, prefix) {
+				continue
+			}
+			if ordinal, found := Ordinal(pvc.Name); found && len(pvc.Name) > len(prefix) && !strings.Contains(pvc.Name[len(prefix):], "-") {
+				return statefulset, ordinal
+			}
+		}
+	}
+	return nil, 0
+}
+
+// returns another claim of the replica of the live StatefulSet the PVC was created for that is mounted, and the pods
+// mounting it. Empty if there is none
+func mountedReplicaClaim(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet, mountingPods map[string][]string) (string, []string) {
+	statefulset, ordinal := ClaimOwner(pvc, statefulsets)
+	if statefulset == nil {
+		return "", nil
+	}
+	for _, claim := range ReplicaClaims(statefulset, ordinal) {
+		if pods, mounted := mountingPods[pvc.Namespace+"/"+claim]; mounted && claim != pvc.Name {
+			return claim, pods
+		}
+	}
+	return "", nil
+}
+
+// a dangling PVC of a replica is kept if another claim of the replica is kept, or exists in the snapshot but was not
+// decided on, for example as its StorageClass is not selected
+func keepReplicas(snapshot *Snapshot, decisions []Decision) {
+	existing := make(map[string]bool)
+	for _, pvc := range snapshot.PVCs {
+		existing[pvc.Namespace+"/"+pvc.Name] = true
+	}
+	dangling := make(map[string]bool)
+	for _, decision := range decisions {
+		existing[decision.PVC.Namespace+"/"+decision.PVC.Name] = true
+		dangling[decision.PVC.Namespace+"/"+decision.PVC.Name] = decision.Dangling
+	}
+	for i := range decisions {
+		decision := &decisions[i]
+		if !decision.Dangling {
+			continue
+		}
+		statefulset, ordinal := ClaimOwner(&decision.PVC, snapshot.StatefulSets)
+		if statefulset == nil {
+			continue
+		}
+		for _, claim := range ReplicaClaims(statefulset, ordinal) {
+			key := decision.PVC.Namespace + "/" + claim
+			if existing[key] && !dangling[key] {
+				decision.Dangling = false
+				decision.Reason = fmt.Sprintf("claim %v of the same replica is kept", claim)
+				break
+			}
+		}
+	}
+}
+
+// Replica returns the namespace, StatefulSet UID and ordinal of the replica the PVC was created for, from its live
+// StatefulSet or else from its lineage, so that the claims of a replica of a deleted StatefulSet share it too. Without
+// either the StatefulSet name is parsed from the <template>-<statefulset>-<ordinal> name of the PVC, taking the template
+// up to the first dash. Empty if the name has no ordinal
+func Replica(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet) string {
+	if statefulset, ordinal := ClaimOwner(pvc, statefulsets); statefulset != nil {
+		return fmt.Sprintf("%v/%v/%v", pvc.Namespace, statefulset.UID, ordinal)
+	}
+	ordinal, found := Ordinal(pvc.Name)
+	if !found {
+		return ""
+	}
+	if lineage := PVCLineage(pvc); lineage != nil {
+		return fmt.Sprintf("%v/%v/%v", pvc.Namespace, lineage.UID, ordinal)
+	}
+	name := pvc.Name[:strings.LastIndex(pvc.Name, "-")]
+	templateIndex := strings.Index(name, "-")
+	if templateIndex == -1 || templateIndex == len(name)-1 {
+		return ""
+	}
+	return fmt.Sprintf("%v/%v/%v", pvc.Namespace, name[templateIndex+1:], ordinal)
+}
+
+// ReplicaClaims are the names of the PVCs the StatefulSet creates for the replica of the ordinal, one per volumeClaimTemplate
+func ReplicaClaims(statefulset *AppsV1.StatefulSet, ordinal int) []string {
+	var claims []string
+	for _, template := range statefulset.Spec.VolumeClaimTemplates {
+		claims = append(claims, fmt.Sprintf("%v-%v-%v", template.Name, statefulset.Name, ordinal))
+	}
+	return claims
+}
+
+// DroppedTemplate returns the live StatefulSet among the given ones that the PVC is named after as <template>-<statefulset>-<ordinal>,
+// and the template, if the StatefulSet has no such volumeClaimTemplate anymore. Nil if there is none
+func DroppedTemplate(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet) (*AppsV1.StatefulSet, string) {
+	if owner, _ := ClaimOwner(pvc, statefulsets); owner != nil {
+		return nil, ""
+	}
+	if _, found := Ordinal(pvc.Name); !found {
+		return nil, ""
+	}
+	ordinalIndex := strings.LastIndex(pvc.Name, "-")
+	for i := range statefulsets {
+		statefulset := &statefulsets[i]
+		suffix := "-" + statefulset.Name
+		if statefulset.Namespace == pvc.Namespace && strings.HasSuffix(pvc.Name[:ordinalIndex], suffix) && len(pvc.Name[:ordinalIndex]) > len(suffix) {
+			return statefulset, pvc.Name[:ordinalIndex-len(suffix)]
+		}
+	}
+	return nil, ""
+}
+
+// WhenDeleted is the whenDeleted policy of the deleted StatefulSet of the PVC. The StatefulSet controller owns the PVC by
+// the StatefulSet if it is Delete, on clusters without the feature gate the when-deleted annotation recorded by the cleaner
+// while the StatefulSet existed is used. Empty if it is not known
+func WhenDeleted(pvc *v1.PersistentVolumeClaim) AppsV1.PersistentVolumeClaimRetentionPolicyType {
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return AppsV1.DeletePersistentVolumeClaimRetentionPolicyType
+		}
+	}
+	policyType, err := retentionPolicyType(pvc.Annotations[constants.WHEN_DELETED_ANNOTATION])
+	if err != nil {
+		return ""
+	}
+	return policyType
+}
+
+// decides on an unmounted PVC by the replicas and retention policy of its StatefulSet. The pod of a replica in the range
+// of the live StatefulSet is only missing for now, a replica out of the range was removed by a scale down. The PVC stays
+// dangling as it is not mounted if the StatefulSet is gone and its whenDeleted policy is not known
+func decideUnmounted(decision *Decision, statefulsets []AppsV1.StatefulSet) {
+	pvc := &decision.PVC
+	if statefulset, ordinal := ReservedBy(pvc, statefulsets); statefulset != nil {
+		decision.Dangling = false
+		decision.Reason = fmt.Sprintf("replica %v of statefulset %v is in range, its pod is missing", ordinal, statefulset.Name)
+		if recreated(pvc, statefulset) {
+			decision.Reason = fmt.Sprintf("reserved for replica %v of statefulset %v, which was recreated after the PVC", ordinal, statefulset.Name)
+		}
+		return
+	}
+	statefulset, ordinal := ClaimOwner(pvc, statefulsets)
+	if statefulset == nil {
+		deleted := "statefulset deleted"
+		if lineage := PVCLineage(pvc); lineage != nil {
+			if owner := LineageOwner(lineage, statefulsets); owner != nil {
+				decision.Dangling = false
+				decision.Reason = fmt.Sprintf("statefulset %v the PVC was created for still exists", owner.Name)
+				return
+			}
+			deleted = fmt.Sprintf("statefulset %v with uid %v deleted", lineage.StatefulSet, lineage.UID)
+			decision.Reason = deleted
+		}
+		switch WhenDeleted(pvc) {
+		case AppsV1.RetainPersistentVolumeClaimRetentionPolicyType:
+			decision.Dangling = false
+			decision.Reason = deleted + ", retained by its whenDeleted=Retain"
+		case AppsV1.DeletePersistentVolumeClaimRetentionPolicyType:
+			decision.Reason = deleted + ", deleted by its whenDeleted=Delete"
+		}
+		return
+	}
+
+	policy, err := RetentionPolicy(statefulset)
+	if err != nil {
+		decision.Dangling = false
+		decision.Reason = fmt.Sprintf("invalid retention policy of statefulset %v, %v", statefulset.Name, err.Error())
+		return
+	}
+	decision.Reason = fmt.Sprintf("replica %v removed by a scale down of statefulset %v", ordinal, statefulset.Name)
+	if policy == nil {
+		return
+	}
+	switch policy.WhenScaled {
+	case AppsV1.RetainPersistentVolumeClaimRetentionPolicyType:
+		decision.Dangling = false
+		decision.Reason += ", retained by its whenScaled=Retain"
+	case AppsV1.DeletePersistentVolumeClaimRetentionPolicyType:
+		decision.Reason += ", deleted by its whenScaled=Delete"
+	}
+}
+
+// ReservedBy returns the live StatefulSet among the given ones that has a replica the PVC is named after, and the ordinal
+// of the replica. The StatefulSet adopts the PVC by its name once the pod of the replica is scheduled, also if the
+// StatefulSet was deleted and recreated with the same name and templates. Nil if there is none
+func ReservedBy(pvc *v1.PersistentVolumeClaim, statefulsets []AppsV1.StatefulSet) (*AppsV1.StatefulSet, int) {
+	statefulset, ordinal := ClaimOwner(pvc, statefulsets)
+	if statefulset == nil || !OrdinalInRange(statefulset, ordinal) {
+		return nil, 0
+	}
+	return statefulset, ordinal
+}
+
+// the PVC was created for an earlier StatefulSet of the same name if it is older than the StatefulSet, or owned by
+// a StatefulSet of another UID or has the lineage of one
+func recreated(pvc *v1.PersistentVolumeClaim, statefulset *AppsV1.StatefulSet) bool {
+	if lineage := PVCLineage(pvc); lineage != nil && lineage.UID != statefulset.UID {
+		return true
+	}
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" && owner.UID != statefulset.UID {
+			return true
+		}
+	}
+	return pvc.CreationTimestamp.Before(&statefulset.CreationTimestamp)
+}
+
+// OrdinalInRange is true if the StatefulSet has a replica of the ordinal, the replicas start at spec.ordinals.start
+func OrdinalInRange(statefulset *AppsV1.StatefulSet, ordinal int) bool {
+	start := 0
+	if statefulset.Spec.Ordinals != nil {
+		start = int(statefulset.Spec.Ordinals.Start)
+	}
+	replicas := 1
+	if statefulset.Spec.Replicas != nil {
+		replicas = int(*statefulset.Spec.Replicas)
+	}
+	return ordinal >= start && ordinal < start+replicas
+}
+
+// Ordinal parses the ordinal of the replica a StatefulSet PVC was created for from its <template>-<statefulset>-<ordinal> name.
+// The StatefulSet controller never names a PVC with a sign or a leading zero, so neither is an ordinal
+func Ordinal(name string) (int, bool) {
+	ordinalIndex := strings.LastIndex(name, "-")
+	if ordinalIndex == -1 {
+		return 0, false
+	}
+	suffix := name[ordinalIndex+1:]
+	ordinal, err := strconv.Atoi(suffix)
+	return ordinal, err == nil && ordinal >= 0 && suffix[0] != '+' && (suffix[0] != '0' || suffix == "0")
+}
@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ApplyResult is the outcome of applying each entry of a saved plan
+type ApplyResult struct {
+	Entries []AppliedEntry `json:"entries"`
+	// Unrecorded is the number of deleted PVCs that could not be recorded in a PVCCleanupRun
+	Unrecorded int `json:"unrecorded,omitempty"`
+}
+
+type AppliedEntry struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Applied   bool   `json:"applied"`
+	// Reason the entry was skipped, empty if it was applied
+	Reason string `json:"reason,omitempty"`
+}
+
+func (r *ApplyResult) PrintTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tACTION\tAPPLIED\tREASON")
+	for _, entry := range r.Entries {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", entry.Namespace, entry.Name, entry.Action, entry.Applied, entry.Reason)
+	}
+	w.Flush()
+	if r.Unrecorded != 0 {
+		fmt.Fprintf(out, "%v deleted PVCs could not be recorded in a PVCCleanupRun\n", r.Unrecorded)
+	}
+}
+
+// SavePlan writes the plan as JSON, the format LoadPlan reads
+func SavePlan(plan *CleanupPlan, path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func LoadPlan(path string) (*CleanupPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plan := &CleanupPlan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %v, %v", path, err.Error())
+	}
+	for i, entry := range plan.Entries {
+		if entry.Namespace == "" || entry.Name == "" || entry.UID == "" {
+			return nil, fmt.Errorf("invalid plan %v, entry %v has no namespace, name or uid", path, i)
+		}
+	}
+	return plan, nil
+}
+
+// Apply acts only on the dangling PVCs of a saved plan. Each of them is looked up again first and skipped if it drifted
+// since the plan was made: if it no longer exists, was recreated with a different UID, is being deleted, is mounted by a
+// pod, is reserved for a replica of a live StatefulSet, its policy changed or its StorageClass lost the annotation. The
+// other claims of the replica of a drifted PVC are skipped too. Orphans are skipped, only clean keeps track of how long
+// they are orphaned. Nothing is applied if the plan goes over the maximum deletions of the limits
+func Apply(clientset kubernetes.Interface, cleanerClientset versioned.Interface, dynamicClient dynamic.Interface, ctx context.Context, plan *CleanupPlan, runLimits limits.Limits) (*ApplyResult, error) {
+	var deletions []string
+	for _, entry := range plan.Entries {
+		if entry.Dangling && entry.Kind == "" && (entry.Action == string(v1alpha1.Delete) || entry.Action == string(v1alpha1.Snapshot)) {
+			deletions = append(deletions, entry.Namespace+"/"+entry.Name)
+		}
+	}
+	if err := runLimits.Check(len(deletions), len(plan.Entries)); err != nil {
+		fmt.Printf("Aborting apply, nothing was applied. PVCs that would have been deleted: %v\n", strings.Join(deletions, ", "))
+		return nil, err
+	}
+
+	recorder := audit.NewRecorder(cleanerClientset)
+	deleter := runLimits.NewDeleter()
+
+	result := &ApplyResult{Entries: []AppliedEntry{}}
+	type pendingEntry struct {
+		index         int
+		entry         PlanEntry
+		pvc           *v1.PersistentVolumeClaim
+		cleanupPolicy *v1alpha1.PVCCleanupPolicy
+	}
+	// entries are checked for drift one by one before any is applied, so that result.Entries does not grow while applying
+	var pending []pendingEntry
+	drifted := make(map[string]string)
+	for _, entry := range plan.Entries {
+		if !entry.Dangling || entry.Action == "Keep" || entry.Action == "Wait" || entry.Action == "Blocked" {
+			continue
+		}
+		applied := AppliedEntry{Namespace: entry.Namespace, Name: entry.Name, Action: entry.Action}
+		if entry.Kind != "" {
+			applied.Reason = fmt.Sprintf("%v entries are only acted on by clean", entry.Kind)
+			result.Entries = append(result.Entries, applied)
+			continue
+		}
+		pvc, cleanupPolicy, drift := checkDrift(clientset, cleanerClientset, ctx, entry)
+		if drift != "" {
+			applied.Reason = drift
+			fmt.Printf("Skipping PVC %v in namespace %v, %v\n", entry.Name, entry.Namespace, drift)
+			if entry.Replica != "" {
+				drifted[entry.Replica] = entry.Name
+			}
+		} else {
+			pending = append(pending, pendingEntry{index: len(result.Entries), entry: entry, pvc: pvc, cleanupPolicy: cleanupPolicy})
+		}
+		result.Entries = append(result.Entries, applied)
+	}
+	// the claims of a replica are applied all or nothing
+	var replicaPending []pendingEntry
+	for _, p := range pending {
+		if claim, found := drifted[p.entry.Replica]; found && p.entry.Replica != "" {
+			result.Entries[p.index].Reason = fmt.Sprintf("claim %v of the same replica drifted", claim)
+			fmt.Printf("Skipping PVC %v in namespace %v, %v\n", p.entry.Name, p.entry.Namespace, result.Entries[p.index].Reason)
+			continue
+		}
+		replicaPending = append(replicaPending, p)
+	}
+
+	for _, p := range replicaPending {
+		applied := &result.Entries[p.index]
+		entry, pvc, cleanupPolicy := p.entry, p.pvc, p.cleanupPolicy
+		deleter.Go(ctx, func(ctx context.Context) {
+			status := v1alpha1.PVCCleanupPolicyStatus{}
+			if cleanupPolicy == nil {
+				reason := fmt.Sprintf("applied from plan, %v", entry.Reason)
+				if err := danglingpvcs.DeletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil {
+					fmt.Printf("Error while deleting dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+					status.Failed++
+				}
+			} else {
+				applyAction(clientset, dynamicClient, ctx, cleanupPolicy, pvc, &status, recorder)
+			}
+			applied.Applied = status.Failed == 0
+			if !applied.Applied {
+				applied.Reason = "failed, see logs"
+			}
+		})
+	}
+	deleter.Wait()
+	if ctx.Err() != nil {
+		fmt.Printf("Apply stopped, %v deletions finished, %v not started\n", deleter.Started(), deleter.Stopped())
+		for i := range result.Entries {
+			if !result.Entries[i].Applied && result.Entries[i].Reason == "" {
+				result.Entries[i].Reason = "not started, apply was stopped"
+			}
+		}
+	}
+	if err := recorder.Complete(ctx); err != nil {
+		fmt.Printf("%v\n", err.Error())
+		result.Unrecorded = recorder.Unrecorded()
+	}
+	return result, nil
+}
+
+// returns the live PVC and the policy of the entry, or why the entry can not be applied anymore
+func checkDrift(clientset kubernetes.Interface, cleanerClientset versioned.Interface, ctx context.Context, entry PlanEntry) (*v1.PersistentVolumeClaim, *v1alpha1.PVCCleanupPolicy, string) {
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(entry.Namespace).Get(ctx, entry.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil, "PVC no longer exists"
+	}
+	if err != nil {
+		return nil, nil, fmt.Sprintf("could not get PVC, %v", err.Error())
+	}
+	if pvc.UID != entry.UID {
+		return nil, nil, fmt.Sprintf("PVC was recreated, uid %v does not match planned uid %v", pvc.UID, entry.UID)
+	}
+	if pvc.DeletionTimestamp != nil {
+		return nil, nil, "PVC is already being deleted"
+	}
+
+	pods, err := clientset.CoreV1().Pods(entry.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Sprintf("could not check pods mounting the PVC, %v", err.Error())
+	}
+	var mountingPods []string
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == entry.Name {
+				mountingPods = append(mountingPods, pod.Name)
+			}
+		}
+	}
+	if len(mountingPods) != 0 {
+		return nil, nil, fmt.Sprintf("PVC is mounted by %v", strings.Join(mountingPods, ","))
+	}
+	statefulsets, err := listers.ListStatefulSets(clientset, ctx, entry.Namespace)
+	if err != nil {
+		return nil, nil, fmt.Sprintf("could not check statefulsets reserving the PVC, %v", err.Error())
+	}
+	if statefulset, ordinal := engine.ReservedBy(pvc, statefulsets); statefulset != nil {
+		return nil, nil, fmt.Sprintf("PVC is reserved for replica %v of statefulset %v", ordinal, statefulset.Name)
+	}
+
+	if entry.Policy == "" {
+		if entry.Action != string(v1alpha1.Delete) {
+			return nil, nil, fmt.Sprintf("unknown action %v", entry.Action)
+		}
+		if pvc.Spec.StorageClassName == nil {
+			return nil, nil, "PVC has no storage class"
+		}
+		storageclass, err := clientset.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, fmt.Sprintf("could not get storage class %v, %v", *pvc.Spec.StorageClassName, err.Error())
+		}
+		if storageclass.Annotations[constants.STORAGE_CLASS_ANNOTATION] != "true" {
+			return nil, nil, fmt.Sprintf("storage class %v no longer has annotation %v=true", storageclass.Name, constants.STORAGE_CLASS_ANNOTATION)
+		}
+		return pvc, nil, ""
+	}
+	if cleanerClientset == nil {
+		return nil, nil, fmt.Sprintf("no client to get PVCCleanupPolicy %v", entry.Policy)
+	}
+	cleanupPolicy, err := cleanerClientset.PVCCleanerV1alpha1().PVCCleanupPolicies().Get(ctx, entry.Policy, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Sprintf("could not get PVCCleanupPolicy %v, %v", entry.Policy, err.Error())
+	}
+	decision := Decision{PVC: *pvc, Dangling: true, Policy: cleanupPolicy}
+	if action := decision.Action(); action != entry.Action {
+		return nil, nil, fmt.Sprintf("action of PVCCleanupPolicy %v changed to %v", entry.Policy, action)
+	}
+	return pvc, cleanupPolicy, ""
+}
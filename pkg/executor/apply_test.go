@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	cleanerfake "github.com/ksraj123/lister-sa/pkg/client/clientset/versioned/fake"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	CoreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]struct {
+		plan        *CleanupPlan
+		expectError bool
+	}{
+		"Saved plan is loaded back": {
+			plan: &CleanupPlan{Entries: []PlanEntry{
+				{Namespace: "default", Name: "pvc-test-sts-0", UID: "1234", Dangling: true, Action: "Delete"},
+			}},
+		},
+		"Entry without uid is rejected": {
+			plan: &CleanupPlan{Entries: []PlanEntry{
+				{Namespace: "default", Name: "pvc-test-sts-0", Dangling: true, Action: "Delete"},
+			}},
+			expectError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "plan.json")
+			if err := SavePlan(test.plan, path); err != nil {
+				t.Fatal(err)
+			}
+			plan, err := LoadPlan(path)
+			if test.expectError {
+				if err == nil {
+					t.Fatalf("Expected error loading %+v", test.plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Entries) != 1 || plan.Entries[0] != test.plan.Entries[0] {
+				t.Fatalf("Expected %+v, got %+v", test.plan.Entries, plan.Entries)
+			}
+		})
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	annotated := generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, nil, constants.LVM_PROVISIONER)
+	quarantinePolicy := &v1alpha1.PVCCleanupPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "quarantine"},
+		Spec:       v1alpha1.PVCCleanupPolicySpec{Action: v1alpha1.Quarantine},
+	}
+	pvc := func() *CoreV1.PersistentVolumeClaim {
+		pvc := generators.GeneratePersistentVolumeClaim("pvc-test-sts-1", constants.TEST_NAMESPACE, "test-sc", nil)
+		pvc.UID = "pvc-uid"
+		return pvc
+	}
+	entry := PlanEntry{Namespace: constants.TEST_NAMESPACE, Name: "pvc-test-sts-1", UID: "pvc-uid", Dangling: true, Action: "Delete"}
+
+	tests := map[string]struct {
+		objects       []runtime.Object
+		entry         PlanEntry
+		expectedDrift string
+	}{
+		"Unchanged PVC of the annotation is applied": {
+			objects: []runtime.Object{pvc(), annotated},
+			entry:   entry,
+		},
+		"Unchanged PVC of a policy is applied": {
+			objects: []runtime.Object{pvc(), annotated},
+			entry:   PlanEntry{Namespace: entry.Namespace, Name: entry.Name, UID: entry.UID, Dangling: true, Action: "Quarantine", Policy: "quarantine"},
+		},
+		"Deleted PVC drifted": {
+			objects:       []runtime.Object{annotated},
+			entry:         entry,
+			expectedDrift: "PVC no longer exists",
+		},
+		"Recreated PVC drifted": {
+			objects:       []runtime.Object{pvc(), annotated},
+			entry:         PlanEntry{Namespace: entry.Namespace, Name: entry.Name, UID: "old-uid", Dangling: true, Action: "Delete"},
+			expectedDrift: "PVC was recreated",
+		},
+		"Terminating PVC drifted": {
+			objects: []runtime.Object{func() *CoreV1.PersistentVolumeClaim {
+				pvc := pvc()
+				now := metav1.Now()
+				pvc.DeletionTimestamp = &now
+				return pvc
+			}(), annotated},
+			entry:         entry,
+			expectedDrift: "PVC is already being deleted",
+		},
+		"Newly mounted PVC drifted": {
+			objects: []runtime.Object{pvc(), annotated, &CoreV1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: constants.TEST_NAMESPACE},
+				Spec: CoreV1.PodSpec{Volumes: []CoreV1.Volume{{
+					Name:         "data",
+					VolumeSource: CoreV1.VolumeSource{PersistentVolumeClaim: &CoreV1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-test-sts-1"}},
+				}}},
+			}},
+			entry:         entry,
+			expectedDrift: "PVC is mounted by app",
+		},
+		"PVC reserved by a scaled up statefulset drifted": {
+			objects:       []runtime.Object{pvc(), annotated, generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 2, nil, "test-sc")},
+			entry:         entry,
+			expectedDrift: "PVC is reserved for replica 1 of statefulset test-sts",
+		},
+		"Changed policy action drifted": {
+			objects:       []runtime.Object{pvc(), annotated},
+			entry:         PlanEntry{Namespace: entry.Namespace, Name: entry.Name, UID: entry.UID, Dangling: true, Action: "Delete", Policy: "quarantine"},
+			expectedDrift: "action of PVCCleanupPolicy quarantine changed to Quarantine",
+		},
+		"Storage class without the annotation anymore drifted": {
+			objects:       []runtime.Object{pvc(), generators.GenerateStorageClass("test-sc", nil, nil, constants.LVM_PROVISIONER)},
+			entry:         entry,
+			expectedDrift: "storage class test-sc no longer has annotation",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(test.objects...)
+			cleanerClientset := cleanerfake.NewSimpleClientset(quarantinePolicy)
+			_, _, drift := checkDrift(clientset, cleanerClientset, context.TODO(), test.entry)
+			if (drift == "") != (test.expectedDrift == "") || !strings.HasPrefix(drift, test.expectedDrift) {
+				t.Fatalf("Expected drift %q, got %q", test.expectedDrift, drift)
+			}
+		})
+	}
+}
+
+func TestApplySkipsReplicaOfDriftedClaim(t *testing.T) {
+	storageclass := generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, nil, constants.LVM_PROVISIONER)
+	data := generators.GeneratePersistentVolumeClaim("data-test-sts-1", constants.TEST_NAMESPACE, "test-sc", nil)
+	data.UID = "data-uid"
+	wal := generators.GeneratePersistentVolumeClaim("wal-test-sts-1", constants.TEST_NAMESPACE, "test-sc", nil)
+	wal.UID = "wal-uid"
+	clientset := fake.NewSimpleClientset(data, wal, storageclass)
+	plan := &CleanupPlan{Entries: []PlanEntry{
+		// data was recreated since the plan was made
+		{Namespace: data.Namespace, Name: data.Name, UID: "old-uid", Dangling: true, Action: "Delete", Replica: "test/test-sts/1"},
+		{Namespace: wal.Namespace, Name: wal.Name, UID: wal.UID, Dangling: true, Action: "Delete", Replica: "test/test-sts/1"},
+	}}
+
+	result, err := Apply(clientset, nil, nil, context.TODO(), plan, limits.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || result.Entries[0].Applied || result.Entries[1].Applied {
+		t.Fatalf("Expected no entry applied, got %+v", result.Entries)
+	}
+	if result.Entries[1].Reason != "claim data-test-sts-1 of the same replica drifted" {
+		t.Fatalf("Expected wal to be skipped for its drifted replica, got %q", result.Entries[1].Reason)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(wal.Namespace).Get(context.TODO(), wal.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("Expected PVC %v to be kept, %v", wal.Name, err)
+	}
+}
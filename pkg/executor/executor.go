@@ -2,40 +2,162 @@ package executor
 
 import (
 	"fmt"
+	"strings"
 
 	"context"
 
-	"github.com/ksraj123/lister-sa/pkg/constants"
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
 	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/limits"
 	"github.com/ksraj123/lister-sa/pkg/listers"
-	"github.com/ksraj123/lister-sa/pkg/statefulsetpvcs"
-	"github.com/ksraj123/lister-sa/pkg/utils"
+	"github.com/ksraj123/lister-sa/pkg/policy"
 
-	StorageV1 "k8s.io/api/storage/v1"
+	AppsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 // ToDo: check if error in one namespace does not stop execution for others
 
-func Execute(clientset *kubernetes.Clientset, ctx context.Context, namespace string) {
-	openEbsStorageClassesMap := make(map[string]*StorageV1.StorageClass)
-	provisioners := utils.EnvVarSlice(constants.PROVISIONERS_ENV_VAR)
-	openEbsStorageClasses := listers.ListProvisionerStorageClassesWithAnnotation(clientset, ctx, provisioners, constants.STORAGE_CLASS_ANNOTATION)
+// Execute evaluates every PVCCleanupPolicy in the cluster against the given namespaces, if there are no policies
+// the StorageClass annotation decides which dangling PVCs are deleted. Then the orphaned PVCs of other workloads are
+// deleted and the opted in StatefulSet replicas that lost the node of their volume, or whose node is unavailable for too
+// long, are recovered. Deleted PVCs are recorded in a PVCCleanupRun, the run fails if some of them could not be.
+// Nothing is deleted if the run would go over the maximum deletions of the limits
+func Execute(clientset kubernetes.Interface, cleanerClientset versioned.Interface, dynamicClient dynamic.Interface, ctx context.Context, namespaces []string, runLimits limits.Limits) (err error) {
+	recorder := audit.NewRecorder(cleanerClientset)
+	deleter := runLimits.NewDeleter()
+	defer func() {
+		if recordErr := recorder.Complete(ctx); err == nil {
+			err = recordErr
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("Run stopped, %v deletions finished, %v not started\n", deleter.Started(), deleter.Stopped())
+		}
+	}()
 
-	if len(openEbsStorageClasses) == 0 {
-		panic("No Valid Storage Classes Found")
+	policies := listers.ListAllCleanupPolicies(cleanerClientset, ctx)
+	decisions, err := planRun(clientset, ctx, namespaces, policies)
+	if err != nil {
+		return err
 	}
+	if runLimits.Force {
+		Force(decisions)
+	}
+	if err := checkLimits(decisions, runLimits); err != nil {
+		return err
+	}
+	recordWhenDeleted(clientset, ctx, decisions)
+
+	var statefulsetDecisions, orphans, losses []Decision
+	for _, decision := range decisions {
+		switch decision.Kind {
+		case "Orphan":
+			orphans = append(orphans, decision)
+		case "NodeLoss":
+			losses = append(losses, decision)
+		default:
+			statefulsetDecisions = append(statefulsetDecisions, decision)
+		}
+	}
+	err = cleanUp(clientset, cleanerClientset, dynamicClient, ctx, namespaces, policies, statefulsetDecisions, recorder, deleter)
+	if orphansErr := CleanOrphans(clientset, dynamicClient, ctx, orphans, recorder, deleter); err == nil {
+		err = orphansErr
+	}
+	if recoverErr := RecoverLostNodes(clientset, dynamicClient, ctx, losses, recorder, deleter); err == nil {
+		err = recoverErr
+	}
+	if err != nil {
+		return err
+	}
+	return stopped(ctx)
+}
+
+// acts on the decisions of the StatefulSet PVCs, by the policies or by the StorageClass annotation if there are none
+func cleanUp(clientset kubernetes.Interface, cleanerClientset versioned.Interface, dynamicClient dynamic.Interface, ctx context.Context, namespaces []string, policies []v1alpha1.PVCCleanupPolicy, decisions []Decision, recorder *audit.Recorder, deleter *limits.Deleter) error {
+	if len(policies) == 0 {
+		for _, namespace := range namespaces {
+			var namespaceDecisions []Decision
+			for _, decision := range decisions {
+				if decision.PVC.Namespace == namespace {
+					namespaceDecisions = append(namespaceDecisions, decision)
+				}
+			}
+			if err := ExecuteWithAnnotation(clientset, dynamicClient, ctx, namespace, namespaceDecisions, recorder, deleter); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := range policies {
+		if policy.Validate(&policies[i]) != nil {
+			continue
+		}
+		var policyDecisions []Decision
+		for _, decision := range decisions {
+			if decision.Policy == &policies[i] {
+				policyDecisions = append(policyDecisions, decision)
+			}
+		}
+		ExecutePolicy(clientset, cleanerClientset, dynamicClient, ctx, &policies[i], policyDecisions, recorder, deleter)
+	}
+	return nil
+}
+
+func stopped(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("run stopped before it finished, %v", ctx.Err())
+	}
+	return nil
+}
 
-	for _, storageclass := range openEbsStorageClasses {
-		openEbsStorageClassesMap[storageclass.Name] = storageclass
-		fmt.Println("OpenEBS storage class with annotation = ", storageclass.Name)
+// ExecuteWithAnnotation deletes the dangling PVCs of the decisions taken by PlanWithAnnotation in the namespace
+func ExecuteWithAnnotation(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, namespace string, decisions []Decision, recorder *audit.Recorder, deleter *limits.Deleter) error {
+	var statefulsetPvcs []v1.PersistentVolumeClaim
+	openebsPVCsStatus := make(map[string]bool)
+	for _, decision := range decisions {
+		fmt.Println(decision.PVC.Name)
+		if decision.Blocked != "" {
+			fmt.Printf("Skipping PVC %v in namespace %v, safety check failed, %v\n", decision.PVC.Name, namespace, decision.Blocked)
+			continue
+		}
+		if decision.Held != "" {
+			fmt.Printf("Skipping PVC %v in namespace %v, %v\n", decision.PVC.Name, namespace, decision.Held)
+			continue
+		}
+		statefulsetPvcs = append(statefulsetPvcs, decision.PVC)
+		openebsPVCsStatus[decision.PVC.Name] = decision.Dangling
 	}
-	openebsPvcs := listers.ListPVCsOfStorageClass(clientset, ctx, namespace, openEbsStorageClasses)
-	statefulsetPvcs := statefulsetpvcs.GetStatefulSetPVCs(clientset, ctx, openebsPvcs, openEbsStorageClassesMap)
-	for _, pvc := range statefulsetPvcs {
-		fmt.Println(pvc.Name)
+	return danglingpvcs.Delete(clientset, dynamicClient, ctx, namespace, statefulsetPvcs, openebsPVCsStatus, recorder, deleter)
+}
+
+// the whenDeleted policy decides on the PVCs once their StatefulSet is deleted. Only Delete is recorded, a PVC of a
+// deleted StatefulSet without a recorded policy is dangling all the same, so the decision does not depend on whether an
+// earlier run saw the StatefulSet
+func recordWhenDeleted(clientset kubernetes.Interface, ctx context.Context, decisions []Decision) {
+	for i := range decisions {
+		if decisions[i].WhenDeleted == string(AppsV1.DeletePersistentVolumeClaimRetentionPolicyType) && ctx.Err() == nil {
+			danglingpvcs.RecordWhenDeleted(clientset, ctx, &decisions[i].PVC, decisions[i].WhenDeleted)
+		}
 	}
+}
 
-	openebsPVCsStatus := danglingpvcs.GetStatusMap(clientset, ctx, namespace, statefulsetPvcs)
-	danglingpvcs.Delete(clientset, ctx, namespace, openebsPVCsStatus)
+// Snapshot decisions count as deletions too, as the PVC is deleted once its snapshot is ready, and so do recovered
+// replicas. All decisions of the run count against the one budget
+func checkLimits(decisions []Decision, runLimits limits.Limits) error {
+	var deletions []string
+	for i := range decisions {
+		switch decisions[i].Action() {
+		case string(v1alpha1.Delete), string(v1alpha1.Snapshot), "Recover":
+			deletions = append(deletions, decisions[i].PVC.Namespace+"/"+decisions[i].PVC.Name)
+		}
+	}
+	if err := runLimits.Check(len(deletions), len(decisions)); err != nil {
+		fmt.Printf("Aborting run, nothing was deleted. PVCs that would have been deleted: %v\n", strings.Join(deletions, ", "))
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/policy"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Rule is one of the conditions a PVC has to meet to be cleaned up
+type Rule struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// Explanation is the outcome of every rule evaluated for a PVC, under one PVCCleanupPolicy or under the StorageClass annotation
+type Explanation struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Policy is empty when the rules of the StorageClass annotation were evaluated
+	Policy   string `json:"policy,omitempty"`
+	Eligible bool   `json:"eligible"`
+	Rules    []Rule `json:"rules"`
+}
+
+type Explanations []Explanation
+
+// the PVC is eligible as long as it meets every rule added
+func (e *Explanation) add(name string, passed bool, detail string, args ...interface{}) {
+	if len(e.Rules) == 0 {
+		e.Eligible = true
+	}
+	e.Rules = append(e.Rules, Rule{Name: name, Passed: passed, Detail: fmt.Sprintf(detail, args...)})
+	e.Eligible = e.Eligible && passed
+}
+
+func (e Explanations) PrintTable(out io.Writer) {
+	for _, explanation := range e {
+		verdict := "ineligible"
+		if explanation.Eligible {
+			verdict = "eligible"
+		}
+		if explanation.Policy == "" {
+			fmt.Fprintf(out, "PVC %v/%v is %v for deletion under the storage class annotation\n", explanation.Namespace, explanation.Name, verdict)
+		} else {
+			fmt.Fprintf(out, "PVC %v/%v is %v for cleanup under PVCCleanupPolicy %v\n", explanation.Namespace, explanation.Name, verdict, explanation.Policy)
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RULE\tRESULT\tDETAIL")
+		for _, rule := range explanation.Rules {
+			result := "fail"
+			if rule.Passed {
+				result = "pass"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\n", rule.Name, result, rule.Detail)
+		}
+		w.Flush()
+		fmt.Fprintln(out)
+	}
+}
+
+// Explain evaluates every rule that makes the PVC eligible or ineligible for cleanup. Rules are not short circuited,
+// so that all reasons a PVC is kept show up at once
+func Explain(clientset kubernetes.Interface, cleanerClientset versioned.Interface, ctx context.Context, namespace string, name string) (Explanations, error) {
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var storageclass *StorageV1.StorageClass
+	if pvc.Spec.StorageClassName != nil {
+		storageclass, err = clientset.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			fmt.Printf("Could not get storage class %v, Error = %v\n", *pvc.Spec.StorageClassName, err.Error())
+			storageclass = nil
+		}
+	}
+	policies := listers.ListAllCleanupPolicies(cleanerClientset, ctx)
+	// the PVC is decided on along with everything else a run in its namespace acts on
+	decisions, err := planRun(clientset, ctx, []string{namespace}, policies)
+	if err != nil && err != ErrNoStorageClasses {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		explanation := Explanation{Namespace: namespace, Name: name}
+		explainStorageClass(&explanation, storageclass)
+		if storageclass != nil {
+			explanation.add("annotation", storageclass.Annotations[constants.STORAGE_CLASS_ANNOTATION] == "true", "storage class %v has annotation %v=%q", storageclass.Name, constants.STORAGE_CLASS_ANNOTATION, storageclass.Annotations[constants.STORAGE_CLASS_ANNOTATION])
+			explainSelectorLabel(&explanation, pvc, storageclass)
+		}
+		explainDecision(&explanation, decisions, pvc, nil)
+		return Explanations{explanation}, nil
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var explanations Explanations
+	for i := range policies {
+		cleanupPolicy := &policies[i]
+		explanation := Explanation{Namespace: namespace, Name: name, Policy: cleanupPolicy.Name}
+		if err := policy.Validate(cleanupPolicy); err != nil {
+			explanation.add("policy", false, "policy is invalid, %v", err.Error())
+		}
+		explanation.add("namespace selector", policy.SelectsNamespace(cleanupPolicy, ns), "namespace %v with labels %v", namespace, ns.Labels)
+		explainStorageClass(&explanation, storageclass)
+		if storageclass != nil {
+			explanation.add("storage class selector", policy.SelectsStorageClass(cleanupPolicy, storageclass), "storage class %v with labels %v", storageclass.Name, storageclass.Labels)
+			if cleanupPolicy.Spec.StatefulSetSelector != nil {
+				selector, _ := metav1.LabelSelectorAsSelector(cleanupPolicy.Spec.StatefulSetSelector)
+				explanation.add("statefulset selector", policy.SelectsPVC(cleanupPolicy, pvc, storageclass), "PVC labels %v matched against %v", pvc.Labels, selector)
+			} else {
+				explainSelectorLabel(&explanation, pvc, storageclass)
+			}
+		}
+		explainDecision(&explanation, decisions, pvc, cleanupPolicy)
+		explainGracePeriod(&explanation, cleanupPolicy, pvc)
+		explainUnusedFor(&explanation, cleanupPolicy, pvc)
+		explanation.add("action", true, "%v", policy.Action(cleanupPolicy))
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
+
+func explainStorageClass(explanation *Explanation, storageclass *StorageV1.StorageClass) {
+	if storageclass == nil {
+		explanation.add("storage class", false, "storage class of the PVC not found")
+		return
+	}
+	provisioners := provisioners.Configured()
+	isProvisioner := false
+	for _, provisioner := range provisioners {
+		if storageclass.Provisioner == provisioner {
+			isProvisioner = true
+		}
+	}
+	explanation.add("provisioner", isProvisioner, "storage class %v uses provisioner %v, configured provisioners are %v", storageclass.Name, storageclass.Provisioner, strings.Join(provisioners, ","))
+}
+
+func explainSelectorLabel(explanation *Explanation, pvc *v1.PersistentVolumeClaim, storageclass *StorageV1.StorageClass) {
+	statefulsetPvcSelector, exists := storageclass.Parameters[constants.STS_PVC_SELECTOR]
+	if !exists {
+		explanation.add("selector label", false, "storage class %v has no %v parameter", storageclass.Name, constants.STS_PVC_SELECTOR)
+		return
+	}
+	explanation.add("selector label", pvc.Labels[statefulsetPvcSelector] == "true", "PVC has label %v=%q", statefulsetPvcSelector, pvc.Labels[statefulsetPvcSelector])
+}
+
+// the decisions are those of a run, so the claims of a replica are held across all policies and a PVC selected by
+// several policies is only decided on by the one that precedes the others
+func explainDecision(explanation *Explanation, decisions []Decision, pvc *v1.PersistentVolumeClaim, cleanupPolicy *v1alpha1.PVCCleanupPolicy) {
+	for i := range decisions {
+		decision := &decisions[i]
+		if decision.PVC.UID != pvc.UID || decision.Kind != "" {
+			continue
+		}
+		if cleanupPolicy != nil && decision.Policy != nil && decision.Policy.Name != cleanupPolicy.Name {
+			explanation.add("policy precedence", false, "PVC is cleaned up under PVCCleanupPolicy %v, which takes precedence", decision.Policy.Name)
+		}
+		explanation.add("dangling", decision.Dangling, "%v", decision.Reason)
+		if decision.Blocked != "" {
+			explanation.add("safety check", false, "%v", decision.Blocked)
+		}
+		if decision.Held != "" {
+			explanation.add("replica", false, "%v", decision.Held)
+		}
+		return
+	}
+	explanation.add("dangling", false, "PVC is not selected as a statefulset PVC")
+}
+
+func explainGracePeriod(explanation *Explanation, cleanupPolicy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim) {
+	if cleanupPolicy.Spec.GracePeriod == nil {
+		explanation.add("grace period", true, "policy has no grace period")
+		return
+	}
+	danglingSince, found := danglingpvcs.DanglingSince(pvc)
+	if !found {
+		explanation.add("grace period", false, "PVC was not found dangling in a previous run, grace period is %v", cleanupPolicy.Spec.GracePeriod.Duration)
+		return
+	}
+	explanation.add("grace period", policy.GracePeriodElapsed(cleanupPolicy, danglingSince, time.Now()), "dangling since %v, grace period is %v", danglingSince.Format(time.RFC3339), cleanupPolicy.Spec.GracePeriod.Duration)
+}
+
+func explainUnusedFor(explanation *Explanation, cleanupPolicy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim) {
+	if cleanupPolicy.Spec.UnusedFor == nil {
+		explanation.add("unused for", true, "policy has no unusedFor")
+		return
+	}
+	if lastUsed, found := danglingpvcs.LastUsed(pvc); found {
+		explanation.add("unused for", policy.UnusedForElapsed(cleanupPolicy, lastUsed, time.Now()), "last used %v, unusedFor is %v", lastUsed.Format(time.RFC3339), cleanupPolicy.Spec.UnusedFor.Duration)
+		return
+	}
+	danglingSince, found := danglingpvcs.DanglingSince(pvc)
+	if !found {
+		explanation.add("unused for", false, "PVC was never seen mounted nor found dangling in a previous run, unusedFor is %v", cleanupPolicy.Spec.UnusedFor.Duration)
+		return
+	}
+	explanation.add("unused for", policy.UnusedForElapsed(cleanupPolicy, danglingSince, time.Now()), "never seen mounted, dangling since %v, unusedFor is %v", danglingSince.Format(time.RFC3339), cleanupPolicy.Spec.UnusedFor.Duration)
+}
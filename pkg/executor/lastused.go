@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TrackLastUsed stamps the last-used annotation on every PVC of the namespaces that a pod which has not finished mounts.
+// A PVC is only patched if its stamp is older than the threshold, so that a PVC mounted all along is patched once per
+// threshold rather than on every run. Returns the number of PVCs stamped
+func TrackLastUsed(clientset kubernetes.Interface, ctx context.Context, namespaces []string, threshold time.Duration) (int, error) {
+	now := time.Now()
+	stamped := 0
+	for _, namespace := range namespaces {
+		pvcs, err := listers.ListPersistentVolumeClaims(clientset, ctx, namespace)
+		if err != nil {
+			return stamped, fmt.Errorf("could not list PVCs of namespace %v, %v", namespace, err.Error())
+		}
+		pods, err := listers.ListPods(clientset, ctx, namespace)
+		if err != nil {
+			return stamped, fmt.Errorf("could not list pods of namespace %v, %v", namespace, err.Error())
+		}
+		used := make(map[string]bool)
+		for _, pod := range pods {
+			if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			for _, volume := range pod.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil {
+					used[volume.PersistentVolumeClaim.ClaimName] = true
+				}
+			}
+		}
+		for i := range pvcs {
+			if !used[pvcs[i].Name] {
+				continue
+			}
+			patched, err := danglingpvcs.StampLastUsed(clientset, ctx, &pvcs[i], now, threshold)
+			if err != nil {
+				fmt.Printf("Could not stamp last used time on PVC %v in namespace %v, Error = %v\n", pvcs[i].Name, namespace, err.Error())
+			} else if patched {
+				stamped++
+			}
+		}
+	}
+	return stamped, nil
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	CoreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTrackLastUsed(t *testing.T) {
+	now := time.Now()
+	pvc := func(lastUsed time.Time) *CoreV1.PersistentVolumeClaim {
+		pvc := generators.GeneratePersistentVolumeClaim("data", constants.TEST_NAMESPACE, "test-sc", nil)
+		if !lastUsed.IsZero() {
+			pvc.Annotations = map[string]string{constants.LAST_USED_ANNOTATION: lastUsed.UTC().Format(time.RFC3339)}
+		}
+		return pvc
+	}
+	pod := func(phase CoreV1.PodPhase) *CoreV1.Pod {
+		return &CoreV1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: constants.TEST_NAMESPACE},
+			Spec: CoreV1.PodSpec{Volumes: []CoreV1.Volume{{
+				Name:         "data",
+				VolumeSource: CoreV1.VolumeSource{PersistentVolumeClaim: &CoreV1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+			}}},
+			Status: CoreV1.PodStatus{Phase: phase},
+		}
+	}
+
+	tests := map[string]struct {
+		pvc             *CoreV1.PersistentVolumeClaim
+		pod             *CoreV1.Pod
+		expectedStamped int
+	}{
+		"Mounted PVC without a stamp is stamped": {
+			pvc:             pvc(time.Time{}),
+			pod:             pod(CoreV1.PodRunning),
+			expectedStamped: 1,
+		},
+		"Stamp older than the threshold is renewed": {
+			pvc:             pvc(now.Add(-2 * time.Hour)),
+			pod:             pod(CoreV1.PodRunning),
+			expectedStamped: 1,
+		},
+		"Stamp within the threshold is not patched": {
+			pvc: pvc(now.Add(-time.Minute)),
+			pod: pod(CoreV1.PodRunning),
+		},
+		"PVC of a finished pod is not stamped": {
+			pvc: pvc(time.Time{}),
+			pod: pod(CoreV1.PodSucceeded),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(test.pvc, test.pod)
+			stamped, err := TrackLastUsed(clientset, context.Background(), []string{constants.TEST_NAMESPACE}, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stamped != test.expectedStamped {
+				t.Fatalf("Expected %v PVCs stamped, got %v", test.expectedStamped, stamped)
+			}
+			if stamped == 0 {
+				return
+			}
+			pvc, err := clientset.CoreV1().PersistentVolumeClaims(constants.TEST_NAMESPACE).Get(context.Background(), "data", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lastUsed, found := danglingpvcs.LastUsed(pvc); !found || now.Sub(lastUsed) > time.Minute {
+				t.Fatalf("Expected last used around %v, got %v", now, pvc.Annotations[constants.LAST_USED_ANNOTATION])
+			}
+		})
+	}
+}
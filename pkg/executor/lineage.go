@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// RecordLineage records the name and UID of the live StatefulSet and the volumeClaimTemplate on every PVC of the
+// namespaces that the StatefulSet reserves for one of its replicas, so that the StatefulSet the PVC was created for is
+// still known once it is deleted. Only PVCs whose lineage changed are patched. Returns the number of PVCs recorded
+func RecordLineage(clientset kubernetes.Interface, ctx context.Context, namespaces []string) (int, error) {
+	recorded := 0
+	for _, namespace := range namespaces {
+		pvcs, err := listers.ListPersistentVolumeClaims(clientset, ctx, namespace)
+		if err != nil {
+			return recorded, fmt.Errorf("could not list PVCs of namespace %v, %v", namespace, err.Error())
+		}
+		statefulsets, err := listers.ListStatefulSets(clientset, ctx, namespace)
+		if err != nil {
+			return recorded, fmt.Errorf("could not list statefulsets of namespace %v, %v", namespace, err.Error())
+		}
+		for i := range pvcs {
+			lineage := engine.LineageOf(&pvcs[i], statefulsets)
+			if lineage == nil {
+				continue
+			}
+			patched, err := danglingpvcs.RecordLineage(clientset, ctx, &pvcs[i], lineage)
+			if err != nil {
+				fmt.Printf("Could not record lineage on PVC %v in namespace %v, Error = %v\n", pvcs[i].Name, namespace, err.Error())
+			} else if patched {
+				recorded++
+			}
+		}
+	}
+	return recorded, nil
+}
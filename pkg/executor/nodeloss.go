@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+	"github.com/ksraj123/lister-sa/pkg/volumesnapshot"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PlanNodeLoss decides which replicas in the given namespaces that opted in with the recover-lost-nodes annotation have a
+// local PV pinned to a node that no longer exists, or to an unavailable node for longer than their unstick-pending
+// annotation allows. All of them are blocked if the safety check of the node loss fails
+func PlanNodeLoss(clientset kubernetes.Interface, ctx context.Context, namespaces []string) []Decision {
+	configured := provisioners.Configured()
+	cluster := &engine.Snapshot{}
+	var err error
+	if cluster.Nodes, err = listers.ListNodes(clientset, ctx); err != nil {
+		cluster.ListErrors = append(cluster.ListErrors, fmt.Errorf("nodes, %v", err.Error()))
+	}
+	if cluster.PersistentVolumes, err = listers.ListPersistentVolumes(clientset, ctx); err != nil {
+		cluster.ListErrors = append(cluster.ListErrors, fmt.Errorf("volumes, %v", err.Error()))
+	}
+	if len(cluster.ListErrors) != 0 {
+		fmt.Printf("Skipping replicas that lost their node, listing failed, %v\n", cluster.ListErrors[0].Error())
+		return nil
+	}
+	blocked := engine.SafetyCheckNodeLoss(cluster, configured)
+
+	var decisions []Decision
+	now := time.Now()
+	for _, namespace := range namespaces {
+		snapshot := snapshotNamespace(clientset, ctx, namespace)
+		snapshot.Nodes = cluster.Nodes
+		snapshot.PersistentVolumes = cluster.PersistentVolumes
+		if snapshot.Events, err = listers.ListEvents(clientset, ctx, namespace); err != nil {
+			snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("Events of namespace %v, %v", namespace, err.Error()))
+		}
+		for _, loss := range engine.DecideNodeLoss(snapshot, configured, now) {
+			loss := loss
+			decisions = append(decisions, Decision{PVC: loss.PVC, Dangling: true, Reason: loss.Reason, Blocked: blocked, Kind: "NodeLoss", NodeLoss: &loss})
+		}
+	}
+	return decisions
+}
+
+// RecoverLostNodes deletes the PVC, the PV and the pending pod of every replica of the decisions taken by PlanNodeLoss.
+// The StatefulSet then recreates the pod and it is scheduled with a fresh volume. Deleted PVCs are recorded with the
+// recorder. Returns the first error once all recoveries are done
+func RecoverLostNodes(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, decisions []Decision, recorder *audit.Recorder, deleter *limits.Deleter) error {
+	var lock sync.Mutex
+	var firstErr error
+	for i := range decisions {
+		loss := decisions[i].NodeLoss
+		if decisions[i].Blocked != "" {
+			fmt.Printf("Skipping replica %v of statefulset %v in namespace %v, safety check failed, %v\n", loss.Ordinal, loss.StatefulSet.Name, loss.PVC.Namespace, decisions[i].Blocked)
+			continue
+		}
+		deleter.Go(ctx, func(ctx context.Context) {
+			if err := recoverReplica(clientset, dynamicClient, ctx, loss, recorder); err != nil {
+				fmt.Printf("Error while recovering replica %v of statefulset %v in namespace %v, Error = %v\n", loss.Ordinal, loss.StatefulSet.Name, loss.PVC.Namespace, err.Error())
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				lock.Unlock()
+			}
+		})
+	}
+	deleter.Wait()
+	return firstErr
+}
+
+// the PVC is deleted first, by the same path as a dangling PVC, so that the PV is released and its reclaim policy or the
+// plugin of its provisioner cleans it up once its node is back. The volume of a node that was removed is always cleaned
+// up by the plugin, so that no PV or LVM or ZFS volume resource is left behind for it. The pending pod holds on to the
+// PVC until it is deleted too.
+// If the unstick-pending policy takes a snapshot, the replica is only recycled in a later run once the snapshot is ready
+func recoverReplica(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, loss *engine.NodeLoss, recorder *audit.Recorder) error {
+	pvc := &loss.PVC
+	reason := loss.Reason
+	if loss.Unstick != nil && loss.Unstick.Snapshot {
+		exists, ready, err := volumesnapshot.Status(dynamicClient, ctx, pvc)
+		if err != nil {
+			return fmt.Errorf("could not get snapshot of PVC %v, %v", pvc.Name, err.Error())
+		}
+		if !exists {
+			if err := volumesnapshot.Create(dynamicClient, ctx, pvc, loss.Unstick.VolumeSnapshotClassName); err != nil {
+				return fmt.Errorf("could not snapshot PVC %v, %v", pvc.Name, err.Error())
+			}
+			fmt.Printf("Snapshot %v of PVC %v in namespace %v created, %v\n", volumesnapshot.Name(pvc), pvc.Name, pvc.Namespace, loss.Reason)
+			return nil
+		}
+		if !ready {
+			fmt.Printf("Snapshot %v of PVC %v in namespace %v is not ready to use yet\n", volumesnapshot.Name(pvc), pvc.Name, pvc.Namespace)
+			return nil
+		}
+		reason += fmt.Sprintf(", snapshot %v ready to use", volumesnapshot.Name(pvc))
+	}
+	deletePVC := danglingpvcs.DeletePVC
+	if loss.Unstick == nil {
+		deletePVC = danglingpvcs.DeleteLostPVC
+	}
+	if err := deletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	for _, pod := range loss.Pods {
+		err := clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(pod.UID))})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("could not delete pending pod %v, %v", pod.Name, err.Error())
+		}
+		fmt.Printf("Pending pod %v in namespace %v deleted to be recreated by statefulset %v\n", pod.Name, pod.Namespace, loss.StatefulSet.Name)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	CoreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// the PVC, the LVM volume it is bound to on node-0 and the pending pod of replica 0 of test-sts
+func generateReplica(reclaimPolicy CoreV1.PersistentVolumeReclaimPolicy) (*CoreV1.PersistentVolumeClaim, *CoreV1.PersistentVolume, *CoreV1.Pod) {
+	pvc := generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", map[string]string{"openebs.io/sts-pvc": "true"})
+	pvc.UID = "pvc-uid"
+	pvc.Spec.VolumeName = "pv-lvm"
+	pv := &CoreV1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-lvm", UID: "pv-uid"},
+		Spec: CoreV1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: reclaimPolicy,
+			ClaimRef:                      &CoreV1.ObjectReference{Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID},
+			PersistentVolumeSource:        CoreV1.PersistentVolumeSource{CSI: &CoreV1.CSIPersistentVolumeSource{Driver: constants.LVM_PROVISIONER, VolumeHandle: "pv-lvm"}},
+			NodeAffinity: &CoreV1.VolumeNodeAffinity{Required: &CoreV1.NodeSelector{NodeSelectorTerms: []CoreV1.NodeSelectorTerm{{
+				MatchExpressions: []CoreV1.NodeSelectorRequirement{{Key: "openebs.io/nodename", Operator: CoreV1.NodeSelectorOpIn, Values: []string{"node-0"}}},
+			}}}},
+		},
+	}
+	pod := &CoreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sts-0", Namespace: constants.TEST_NAMESPACE, UID: "pod-uid"},
+		Spec: CoreV1.PodSpec{Volumes: []CoreV1.Volume{{
+			Name:         "pvc",
+			VolumeSource: CoreV1.VolumeSource{PersistentVolumeClaim: &CoreV1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name}},
+		}}},
+		Status: CoreV1.PodStatus{Phase: CoreV1.PodPending},
+	}
+	return pvc, pv, pod
+}
+
+// a dynamic client holding the LVMVolume of the volume, with no LVMSnapshots
+func newLVMClient(pv *CoreV1.PersistentVolume) dynamic.Interface {
+	lvmVolume := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": provisioners.LVMVolumeResource.GroupVersion().String(),
+		"kind":       "LVMVolume",
+		"metadata":   map[string]interface{}{"name": pv.Spec.CSI.VolumeHandle, "namespace": constants.OPENEBS_NAMESPACe},
+	}}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		provisioners.LVMVolumeResource:   "LVMVolumeList",
+		provisioners.LVMSnapshotResource: "LVMSnapshotList",
+	}, lvmVolume)
+}
+
+func lvmVolumeExists(t *testing.T, dynamicClient dynamic.Interface, name string) bool {
+	volumes, err := dynamicClient.Resource(provisioners.LVMVolumeResource).Namespace(constants.OPENEBS_NAMESPACe).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, volume := range volumes.Items {
+		if volume.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRecoverUnstuckReplica(t *testing.T) {
+	tests := map[string]struct {
+		reclaimPolicy       CoreV1.PersistentVolumeReclaimPolicy
+		expectVolumeDeleted bool
+		expectLVMVolumeGone bool
+	}{
+		"Delete volume is left to its reclaim policy": {
+			reclaimPolicy: CoreV1.PersistentVolumeReclaimDelete,
+		},
+		"Retain volume is cleaned up by its plugin": {
+			reclaimPolicy:       CoreV1.PersistentVolumeReclaimRetain,
+			expectVolumeDeleted: true,
+			expectLVMVolumeGone: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pvc, pv, pod := generateReplica(test.reclaimPolicy)
+			storageclass := generators.GenerateStorageClass("test-sc", map[string]string{constants.CLEANUP_RETAINED_ANNOTATION: "true"}, nil, constants.LVM_PROVISIONER)
+			statefulset := generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, nil, "test-sc")
+			clientset := fake.NewSimpleClientset(pvc, pv, pod, storageclass)
+			dynamicClient := newLVMClient(pv)
+			decision := Decision{PVC: *pvc, Dangling: true, Kind: "NodeLoss", NodeLoss: &engine.NodeLoss{
+				PVC:         *pvc,
+				Volume:      pv,
+				StatefulSet: statefulset,
+				Pods:        []CoreV1.Pod{*pod},
+				Unstick:     &engine.UnstickPolicy{PendingFor: time.Hour},
+				Reason:      "pod test-sts-0 pending for more than 1h0m0s",
+			}}
+
+			if err := RecoverLostNodes(clientset, dynamicClient, context.TODO(), []Decision{decision}, nil, limits.Limits{}.NewDeleter()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(context.TODO(), pvc.Name, metav1.GetOptions{}); err == nil {
+				t.Fatalf("Expected PVC %v to be deleted", pvc.Name)
+			}
+			if _, err := clientset.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{}); err == nil {
+				t.Fatalf("Expected pending pod %v to be deleted", pod.Name)
+			}
+			_, err := clientset.CoreV1().PersistentVolumes().Get(context.TODO(), pv.Name, metav1.GetOptions{})
+			if (err != nil) != test.expectVolumeDeleted {
+				t.Fatalf("Expected volume deleted %v, got error %v", test.expectVolumeDeleted, err)
+			}
+			if lvmVolumeExists(t, dynamicClient, pv.Name) == test.expectLVMVolumeGone {
+				t.Fatalf("Expected LVMVolume %v gone %v", pv.Name, test.expectLVMVolumeGone)
+			}
+		})
+	}
+}
+
+func TestPlanAndRecoverLostNodes(t *testing.T) {
+	tests := map[string]struct {
+		nodes         []string
+		expectDeleted bool
+	}{
+		"replica on a removed node is recovered": {
+			nodes:         []string{"node-1"},
+			expectDeleted: true,
+		},
+		"replica on a present node is left alone": {
+			nodes: []string{"node-0", "node-1"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pvc, pv, pod := generateReplica(CoreV1.PersistentVolumeReclaimDelete)
+			statefulset := generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, nil, "test-sc")
+			statefulset.Annotations = map[string]string{constants.RECOVER_LOST_NODES_ANNOTATION: "*"}
+			objects := []runtime.Object{pvc, pv, pod, statefulset}
+			for _, name := range test.nodes {
+				objects = append(objects, &CoreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"openebs.io/nodename": name}}})
+			}
+			clientset := fake.NewSimpleClientset(objects...)
+			dynamicClient := newLVMClient(pv)
+
+			decisions := PlanNodeLoss(clientset, context.TODO(), []string{constants.TEST_NAMESPACE})
+			if len(decisions) != map[bool]int{true: 1, false: 0}[test.expectDeleted] {
+				t.Fatalf("Expected replica recovered %v, got decisions %+v", test.expectDeleted, decisions)
+			}
+			if err := RecoverLostNodes(clientset, dynamicClient, context.TODO(), decisions, nil, limits.Limits{}.NewDeleter()); err != nil {
+				t.Fatal(err)
+			}
+			_, err := clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(context.TODO(), pvc.Name, metav1.GetOptions{})
+			if (err != nil) != test.expectDeleted {
+				t.Fatalf("Expected PVC deleted %v, got error %v", test.expectDeleted, err)
+			}
+			_, err = clientset.CoreV1().PersistentVolumes().Get(context.TODO(), pv.Name, metav1.GetOptions{})
+			if (err != nil) != test.expectDeleted {
+				t.Fatalf("Expected volume deleted %v, got error %v", test.expectDeleted, err)
+			}
+			_, err = clientset.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			if (err != nil) != test.expectDeleted {
+				t.Fatalf("Expected pending pod deleted %v, got error %v", test.expectDeleted, err)
+			}
+			if lvmVolumeExists(t, dynamicClient, pv.Name) == test.expectDeleted {
+				t.Fatalf("Expected LVMVolume %v deleted %v", pv.Name, test.expectDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+
+	StorageV1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PlanOrphans decides which PVCs of non-StatefulSet workloads in the given namespaces are orphans, for the StorageClasses
+// with the delete-orphan-pvc-after-days annotation. A namespace is skipped if one of its lists failed
+func PlanOrphans(clientset kubernetes.Interface, ctx context.Context, namespaces []string) []Decision {
+	storageClasses := listers.ListAllStorageClasses(clientset, ctx)
+	enabled := false
+	for i := range storageClasses {
+		_, after := engine.OrphanAfter(&storageClasses[i])
+		enabled = enabled || after
+	}
+	if !enabled {
+		return nil
+	}
+	var decisions []Decision
+	for _, namespace := range namespaces {
+		snapshot := snapshotWorkloads(clientset, ctx, namespace)
+		snapshot.StorageClasses = storageClasses
+		if len(snapshot.ListErrors) != 0 {
+			fmt.Printf("Skipping orphaned PVCs of namespace %v, listing failed, %v\n", namespace, snapshot.ListErrors[0].Error())
+			continue
+		}
+		for _, decision := range engine.DecideOrphans(snapshot, provisioners.Configured()) {
+			after, _ := engine.OrphanAfter(storageClass(storageClasses, *decision.PVC.Spec.StorageClassName))
+			decisions = append(decisions, Decision{PVC: decision.PVC, Dangling: decision.Dangling, Reason: decision.Reason, Kind: "Orphan", OrphanAfter: after})
+		}
+	}
+	return decisions
+}
+
+// CleanOrphans deletes the orphans of the decisions taken by PlanOrphans that stayed orphaned for as long as the
+// delete-orphan-pvc-after-days annotation of their StorageClass allows. The time a PVC was first found orphaned is kept
+// in its dangling-since annotation. Returns the first error once all deletions are done
+func CleanOrphans(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, decisions []Decision, recorder *audit.Recorder, deleter *limits.Deleter) error {
+	now := time.Now()
+	var lock sync.Mutex
+	var firstErr error
+	for i := range decisions {
+		decision := &decisions[i]
+		pvc := &decision.PVC
+		if !decision.Dangling {
+			danglingpvcs.UnmarkDangling(clientset, ctx, pvc)
+			continue
+		}
+		if decision.Blocked != "" {
+			fmt.Printf("Skipping orphaned PVC %v in namespace %v, safety check failed, %v\n", pvc.Name, pvc.Namespace, decision.Blocked)
+			continue
+		}
+		orphanSince := danglingpvcs.MarkDangling(clientset, ctx, pvc, now)
+		if now.Sub(orphanSince) < decision.OrphanAfter {
+			fmt.Printf("Orphaned PVC %v in namespace %v is kept until %v\n", pvc.Name, pvc.Namespace, orphanSince.Add(decision.OrphanAfter).UTC().Format(time.RFC3339))
+			continue
+		}
+		reason := fmt.Sprintf("%v for more than %v", decision.Reason, decision.OrphanAfter)
+		deleter.Go(ctx, func(ctx context.Context) {
+			if err := danglingpvcs.DeletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil {
+				fmt.Printf("Error while deleting orphaned PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				lock.Unlock()
+			}
+		})
+	}
+	deleter.Wait()
+	return firstErr
+}
+
+// lists the PVCs, Pods, StatefulSets and the other workloads of the namespace, keeping the list errors
+func snapshotWorkloads(clientset kubernetes.Interface, ctx context.Context, namespace string) *engine.Snapshot {
+	snapshot := snapshotNamespace(clientset, ctx, namespace)
+	var err error
+	if snapshot.Deployments, err = listers.ListDeployments(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("Deployments of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.ReplicaSets, err = listers.ListReplicaSets(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("ReplicaSets of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.DaemonSets, err = listers.ListDaemonSets(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("DaemonSets of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.Jobs, err = listers.ListJobs(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("Jobs of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.CronJobs, err = listers.ListCronJobs(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("CronJobs of namespace %v, %v", namespace, err.Error()))
+	}
+	return snapshot
+}
+
+func storageClass(storageClasses []StorageV1.StorageClass, name string) *StorageV1.StorageClass {
+	for i := range storageClasses {
+		if storageClasses[i].Name == name {
+			return &storageClasses[i]
+		}
+	}
+	return &StorageV1.StorageClass{}
+}
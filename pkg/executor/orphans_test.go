@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	CoreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCleanOrphans(t *testing.T) {
+	now := time.Now()
+	storageclass := generators.GenerateStorageClass("test-sc", map[string]string{constants.ORPHAN_ANNOTATION: "2"}, nil, constants.HOSTPATH_PROVISIONER)
+	pvc := func(orphanSince time.Time, annotations map[string]string) *CoreV1.PersistentVolumeClaim {
+		pvc := generators.GeneratePersistentVolumeClaim("app-data", constants.TEST_NAMESPACE, "test-sc", nil)
+		pvc.UID = "pvc-uid"
+		pvc.Annotations = annotations
+		if !orphanSince.IsZero() {
+			if pvc.Annotations == nil {
+				pvc.Annotations = map[string]string{}
+			}
+			pvc.Annotations[constants.DANGLING_SINCE_ANNOTATION] = orphanSince.UTC().Format(time.RFC3339)
+		}
+		return pvc
+	}
+
+	tests := map[string]struct {
+		pvc           *CoreV1.PersistentVolumeClaim
+		expectDeleted bool
+		expectStamped bool
+	}{
+		"Orphan found for the first time is stamped and kept": {
+			pvc:           pvc(time.Time{}, nil),
+			expectStamped: true,
+		},
+		"Orphan is kept until the days of its storage class passed": {
+			pvc:           pvc(now.Add(-24*time.Hour), nil),
+			expectStamped: true,
+		},
+		"Orphan is deleted once the days of its storage class passed": {
+			pvc:           pvc(now.Add(-72*time.Hour), nil),
+			expectDeleted: true,
+		},
+		"Protected orphan is never deleted": {
+			pvc: pvc(now.Add(-720*time.Hour), map[string]string{constants.PROTECTED_ANNOTATION: "true"}),
+		},
+		"Orphan kept by helm is never deleted": {
+			pvc: pvc(now.Add(-720*time.Hour), map[string]string{constants.HELM_RESOURCE_POLICY: "keep"}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(test.pvc, storageclass)
+			decisions := PlanOrphans(clientset, context.TODO(), []string{constants.TEST_NAMESPACE})
+			if len(decisions) != 1 {
+				t.Fatalf("Expected a decision on the PVC, got %v", len(decisions))
+			}
+			if err := CleanOrphans(clientset, nil, context.TODO(), decisions, nil, limits.Limits{}.NewDeleter()); err != nil {
+				t.Fatal(err)
+			}
+			pvc, err := clientset.CoreV1().PersistentVolumeClaims(test.pvc.Namespace).Get(context.TODO(), test.pvc.Name, metav1.GetOptions{})
+			if (err != nil) != test.expectDeleted {
+				t.Fatalf("Expected PVC deleted %v, got error %v", test.expectDeleted, err)
+			}
+			if test.expectDeleted {
+				return
+			}
+			if _, stamped := danglingpvcs.DanglingSince(pvc); stamped != test.expectStamped {
+				t.Fatalf("Expected PVC stamped orphaned %v, got annotations %v", test.expectStamped, pvc.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/policy"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+var ErrNoStorageClasses = errors.New("No Valid Storage Classes Found")
+
+// Decision is the dangling status of a StatefulSet PVC selected for cleanup, or of an orphaned PVC of another workload
+type Decision struct {
+	PVC      v1.PersistentVolumeClaim
+	Dangling bool
+	// Policy that selected the PVC, nil if it was selected by the StorageClass annotation
+	Policy *v1alpha1.PVCCleanupPolicy
+	// Reason for the dangling status, a generic one is used in the plan if empty
+	Reason string
+	// Blocked is why the safety check of the namespace failed, nothing is done with a blocked PVC unless forced
+	Blocked string
+	// WhenDeleted is the whenDeleted retention policy of the live StatefulSet of the PVC, empty if it has none
+	WhenDeleted string
+	// DroppedTemplate is the volumeClaimTemplate of the PVC if it was dropped from its live StatefulSet
+	DroppedTemplate string
+	// Kind is Orphan for a PVC of a non-StatefulSet workload, NodeLoss for the PVC of a replica that lost the node of its
+	// volume and empty for a StatefulSet PVC
+	Kind string
+	// OrphanAfter is how long an orphan has to stay orphaned before it is deleted
+	OrphanAfter time.Duration
+	// NodeLoss is the replica that is recovered along with the PVC, nil unless the Kind is NodeLoss
+	NodeLoss *engine.NodeLoss
+	// Replica identifies the StatefulSet replica of the PVC, empty if it is unknown
+	Replica string
+	// Held is why the PVC waits for another claim of its replica that is not deleted, see holdReplicas
+	Held string
+}
+
+// Action is what clean does with the PVC, Keep if it is not dangling and Wait if it is within the grace period of its
+// policy, was used too recently for it or is held by another claim of its replica. The replica of a PVC of the NodeLoss
+// kind is recovered
+func (d *Decision) Action() string {
+	if d.Blocked != "" {
+		return "Blocked"
+	}
+	if !d.Dangling {
+		return "Keep"
+	}
+	if d.Held != "" {
+		return "Wait"
+	}
+	if d.Kind == "NodeLoss" {
+		return "Recover"
+	}
+	if d.Kind == "Orphan" {
+		if !orphanAfterElapsed(d.OrphanAfter, &d.PVC, time.Now()) {
+			return "Wait"
+		}
+		return string(v1alpha1.Delete)
+	}
+	if d.Policy == nil {
+		return string(v1alpha1.Delete)
+	}
+	if !gracePeriodElapsed(d.Policy, &d.PVC, time.Now()) || !unusedForElapsed(d.Policy, &d.PVC, time.Now()) {
+		return "Wait"
+	}
+	return string(policy.Action(d.Policy))
+}
+
+// a PVC that was not found dangling by a previous run is only due if the policy has no grace period
+func gracePeriodElapsed(cleanupPolicy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim, now time.Time) bool {
+	danglingSince, found := danglingpvcs.DanglingSince(pvc)
+	if !found {
+		danglingSince = now
+	}
+	return policy.GracePeriodElapsed(cleanupPolicy, danglingSince, now)
+}
+
+// a PVC that was never seen mounted counts as last used when it was found dangling, now if it was not found dangling before
+func unusedForElapsed(cleanupPolicy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim, now time.Time) bool {
+	lastUsed, found := danglingpvcs.LastUsed(pvc)
+	if !found {
+		lastUsed, found = danglingpvcs.DanglingSince(pvc)
+	}
+	if !found {
+		lastUsed = now
+	}
+	return policy.UnusedForElapsed(cleanupPolicy, lastUsed, now)
+}
+
+// an orphan that was not found dangling by a previous run is first marked dangling by this one
+func orphanAfterElapsed(after time.Duration, pvc *v1.PersistentVolumeClaim, now time.Time) bool {
+	orphanSince, found := danglingpvcs.DanglingSince(pvc)
+	if !found {
+		orphanSince = now
+	}
+	return now.Sub(orphanSince) >= after
+}
+
+// deletes reports whether the action deletes the PVC
+func deletes(action string) bool {
+	return action == string(v1alpha1.Delete) || action == string(v1alpha1.Snapshot)
+}
+
+// holdReplicas makes the claims of a StatefulSet replica all-or-nothing after every per-PVC filter: a claim that would be
+// deleted is held while another claim of the same replica is not, whether it is kept, blocked, within its grace period,
+// used too recently or only quarantined
+func holdReplicas(decisions []Decision) {
+	kept := make(map[string]string)
+	for i := range decisions {
+		decisions[i].Held = ""
+	}
+	for i := range decisions {
+		decision := &decisions[i]
+		if decision.Kind != "" || decision.Replica == "" {
+			continue
+		}
+		if _, found := kept[decision.Replica]; !found {
+			if action := decision.Action(); !deletes(action) {
+				kept[decision.Replica] = fmt.Sprintf("claim %v of the same replica is not deleted, its action is %v", decision.PVC.Name, action)
+			}
+		}
+	}
+	for i := range decisions {
+		decision := &decisions[i]
+		if held, found := kept[decision.Replica]; found && decision.Kind == "" && deletes(decision.Action()) {
+			decision.Held = held
+		}
+	}
+}
+
+// CleanupPlan is the structured form of the decisions taken in one run
+type CleanupPlan struct {
+	Entries []PlanEntry `json:"entries"`
+}
+
+// PlanEntry identifies the exact PVC a decision was taken on
+type PlanEntry struct {
+	Namespace        string    `json:"namespace"`
+	Name             string    `json:"name"`
+	UID              types.UID `json:"uid"`
+	StorageClassName string    `json:"storageClassName"`
+	VolumeName       string    `json:"volumeName,omitempty"`
+	Dangling         bool      `json:"dangling"`
+	Action           string    `json:"action"`
+	// Policy is empty if the PVC was selected by the StorageClass annotation
+	Policy string `json:"policy,omitempty"`
+	Reason string `json:"reason"`
+	// DroppedTemplate is the volumeClaimTemplate of a kept PVC that was dropped from its live StatefulSet
+	DroppedTemplate string `json:"droppedTemplate,omitempty"`
+	// Kind is Orphan for a PVC of a non-StatefulSet workload, NodeLoss for the PVC of a replica that lost the node of its
+	// volume and empty for a StatefulSet PVC
+	Kind string `json:"kind,omitempty"`
+	// Replica identifies the StatefulSet replica of the PVC, apply skips the other claims of a replica once one of them drifted
+	Replica string `json:"replica,omitempty"`
+}
+
+func NewCleanupPlan(decisions []Decision) *CleanupPlan {
+	plan := &CleanupPlan{Entries: []PlanEntry{}}
+	for i := range decisions {
+		decision := &decisions[i]
+		entry := PlanEntry{
+			Namespace:       decision.PVC.Namespace,
+			Name:            decision.PVC.Name,
+			UID:             decision.PVC.UID,
+			VolumeName:      decision.PVC.Spec.VolumeName,
+			Dangling:        decision.Dangling,
+			Action:          decision.Action(),
+			Reason:          "mounted by a statefulset pod",
+			DroppedTemplate: decision.DroppedTemplate,
+			Kind:            decision.Kind,
+			Replica:         decision.Replica,
+		}
+		if decision.PVC.Spec.StorageClassName != nil {
+			entry.StorageClassName = *decision.PVC.Spec.StorageClassName
+		}
+		if decision.Policy != nil {
+			entry.Policy = decision.Policy.Name
+		}
+		if decision.Dangling {
+			entry.Reason = "not mounted by any statefulset pod"
+		}
+		if decision.Reason != "" {
+			entry.Reason = decision.Reason
+		}
+		if decision.Held != "" {
+			entry.Reason = decision.Held
+		}
+		if decision.Blocked != "" {
+			entry.Reason = "safety check failed, " + decision.Blocked
+		}
+		plan.Entries = append(plan.Entries, entry)
+	}
+	return plan
+}
+
+func (p *CleanupPlan) PrintTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tDANGLING\tACTION\tPOLICY\tREASON")
+	for _, entry := range p.Entries {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", entry.Namespace, entry.Name, entry.Dangling, entry.Action, orNone(entry.Policy), entry.Reason)
+	}
+	w.Flush()
+}
+
+// Plan decides which StatefulSet PVCs in the given namespaces are dangling, using the PVCCleanupPolicies in the cluster
+// or the StorageClass annotation if there are none, which PVCs of other workloads are orphans and which replicas lost the
+// node of their volume. Nothing is changed in the cluster
+func Plan(clientset kubernetes.Interface, cleanerClientset versioned.Interface, ctx context.Context, namespaces []string) ([]Decision, error) {
+	return planRun(clientset, ctx, namespaces, listers.ListAllCleanupPolicies(cleanerClientset, ctx))
+}
+
+// the decisions of everything a run acts on, the StatefulSet PVCs first. The orphans and node losses are still planned if
+// no StorageClass is selected for StatefulSet PVCs. The claims of a replica are held together across all policies
+func planRun(clientset kubernetes.Interface, ctx context.Context, namespaces []string, policies []v1alpha1.PVCCleanupPolicy) ([]Decision, error) {
+	decisions, err := plan(clientset, ctx, namespaces, policies)
+	if err != nil && err != ErrNoStorageClasses {
+		return nil, err
+	}
+	others := append(PlanOrphans(clientset, ctx, namespaces), PlanNodeLoss(clientset, ctx, namespaces)...)
+	if err == ErrNoStorageClasses && len(others) == 0 {
+		return nil, err
+	}
+	decisions = append(decisions, others...)
+	holdReplicas(decisions)
+	return decisions, nil
+}
+
+// the Policy of each decision points into policies
+func plan(clientset kubernetes.Interface, ctx context.Context, namespaces []string, policies []v1alpha1.PVCCleanupPolicy) ([]Decision, error) {
+	var decisions []Decision
+	if len(policies) == 0 {
+		for _, namespace := range namespaces {
+			namespaceDecisions, err := PlanWithAnnotation(clientset, ctx, namespace)
+			if err != nil {
+				return nil, err
+			}
+			decisions = append(decisions, namespaceDecisions...)
+		}
+		return decisions, nil
+	}
+	for i := range policies {
+		if err := policy.Validate(&policies[i]); err != nil {
+			fmt.Printf("Skipping PVCCleanupPolicy %v, Error = %v\n", policies[i].Name, err.Error())
+			continue
+		}
+		decisions = append(decisions, PlanPolicy(clientset, ctx, namespaces, &policies[i])...)
+	}
+	return onePerPVC(decisions), nil
+}
+
+// onePerPVC keeps a single decision for a PVC selected by several policies, the one of the policy that precedes the
+// others, so that it is acted on and counted against the limits once
+func onePerPVC(decisions []Decision) []Decision {
+	index := make(map[types.UID]int)
+	var unique []Decision
+	for _, decision := range decisions {
+		i, found := index[decision.PVC.UID]
+		if !found {
+			index[decision.PVC.UID] = len(unique)
+			unique = append(unique, decision)
+			continue
+		}
+		if policy.Precedes(decision.Policy, unique[i].Policy) {
+			unique[i] = decision
+		}
+	}
+	return unique
+}
+
+// PlanWithAnnotation decides which StatefulSet PVCs of StorageClasses with the delete-dangling-pvc annotation are dangling
+func PlanWithAnnotation(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]Decision, error) {
+	provisioners := provisioners.Configured()
+	storageClasses := listers.ListAllStorageClasses(clientset, ctx)
+	if len(engine.SelectedStorageClasses(storageClasses, provisioners)) == 0 {
+		return nil, ErrNoStorageClasses
+	}
+	snapshot := snapshotNamespace(clientset, ctx, namespace)
+	snapshot.StorageClasses = storageClasses
+	return newDecisions(snapshot, engine.Decide(snapshot, provisioners), nil), nil
+}
+
+// PlanPolicy decides which StatefulSet PVCs selected by the policy are dangling
+func PlanPolicy(clientset kubernetes.Interface, ctx context.Context, namespaces []string, cleanupPolicy *v1alpha1.PVCCleanupPolicy) []Decision {
+	provisioners := provisioners.Configured()
+	storageClassesMap := make(map[string]*StorageV1.StorageClass)
+	for _, storageclass := range listers.ListProvisionerStorageClasses(clientset, ctx, provisioners) {
+		if policy.SelectsStorageClass(cleanupPolicy, storageclass) {
+			storageClassesMap[storageclass.Name] = storageclass
+		}
+	}
+	if len(storageClassesMap) == 0 {
+		return nil
+	}
+
+	var decisions []Decision
+	for _, namespace := range namespaces {
+		ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if err != nil {
+			fmt.Printf("Could not get namespace %v, Error = %v\n", namespace, err.Error())
+			continue
+		}
+		if !policy.SelectsNamespace(cleanupPolicy, ns) {
+			continue
+		}
+
+		snapshot := snapshotNamespace(clientset, ctx, namespace)
+		var statefulsetPvcs []v1.PersistentVolumeClaim
+		for _, pvc := range snapshot.PVCs {
+			if pvc.Spec.StorageClassName == nil {
+				continue
+			}
+			storageclass, exists := storageClassesMap[*pvc.Spec.StorageClassName]
+			if exists && policy.SelectsPVC(cleanupPolicy, &pvc, storageclass) {
+				statefulsetPvcs = append(statefulsetPvcs, pvc)
+			}
+		}
+		decisions = append(decisions, newDecisions(snapshot, engine.DecideDangling(snapshot, statefulsetPvcs), cleanupPolicy)...)
+	}
+	return decisions
+}
+
+// lists the PVCs, StatefulSets and Pods of the namespace, keeping the list errors for the safety check
+func snapshotNamespace(clientset kubernetes.Interface, ctx context.Context, namespace string) *engine.Snapshot {
+	snapshot := &engine.Snapshot{}
+	var err error
+	if snapshot.PVCs, err = listers.ListPersistentVolumeClaims(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("PVCs of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.StatefulSets, err = listers.ListStatefulSets(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("StatefulSets of namespace %v, %v", namespace, err.Error()))
+	}
+	if snapshot.Pods, err = listers.ListPods(clientset, ctx, namespace); err != nil {
+		snapshot.ListErrors = append(snapshot.ListErrors, fmt.Errorf("Pods of namespace %v, %v", namespace, err.Error()))
+	}
+	return snapshot
+}
+
+// blocks all decisions of the namespace if its safety check fails
+func newDecisions(snapshot *engine.Snapshot, engineDecisions []engine.Decision, cleanupPolicy *v1alpha1.PVCCleanupPolicy) []Decision {
+	blocked := engine.SafetyCheck(snapshot, engineDecisions)
+	var decisions []Decision
+	for _, decision := range engineDecisions {
+		decisions = append(decisions, Decision{PVC: decision.PVC, Dangling: decision.Dangling, Policy: cleanupPolicy, Reason: decision.Reason, Blocked: blocked, WhenDeleted: string(decision.WhenDeleted), DroppedTemplate: decision.DroppedTemplate, Replica: decision.Replica})
+	}
+	return decisions
+}
+
+// Force lifts the safety check blocks of the decisions, the claims of a replica are still held together
+func Force(decisions []Decision) {
+	for i := range decisions {
+		decisions[i].Blocked = ""
+	}
+	holdReplicas(decisions)
+}
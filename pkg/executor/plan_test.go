@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewCleanupPlan(t *testing.T) {
+	pvc := generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	quarantinePolicy := &v1alpha1.PVCCleanupPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "quarantine"},
+		Spec:       v1alpha1.PVCCleanupPolicySpec{Action: v1alpha1.Quarantine},
+	}
+
+	orphan := pvc.DeepCopy()
+	orphan.Annotations = map[string]string{constants.DANGLING_SINCE_ANNOTATION: time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)}
+
+	tests := map[string]struct {
+		decision       Decision
+		expectedAction string
+		expectedPolicy string
+	}{
+		"Mounted PVC is kept": {
+			decision:       Decision{PVC: *pvc, Dangling: false},
+			expectedAction: "Keep",
+		},
+		"Dangling PVC selected by the annotation is deleted": {
+			decision:       Decision{PVC: *pvc, Dangling: true},
+			expectedAction: "Delete",
+		},
+		"Dangling PVC selected by a policy gets the policy action": {
+			decision:       Decision{PVC: *pvc, Dangling: true, Policy: quarantinePolicy},
+			expectedAction: "Quarantine",
+			expectedPolicy: "quarantine",
+		},
+		"Orphan is deleted once it stayed orphaned long enough": {
+			decision:       Decision{PVC: *orphan, Dangling: true, Kind: "Orphan", OrphanAfter: 24 * time.Hour},
+			expectedAction: "Delete",
+		},
+		"Orphan waits until it stayed orphaned long enough": {
+			decision:       Decision{PVC: *orphan, Dangling: true, Kind: "Orphan", OrphanAfter: 72 * time.Hour},
+			expectedAction: "Wait",
+		},
+		"Orphan not found orphaned before waits": {
+			decision:       Decision{PVC: *pvc, Dangling: true, Kind: "Orphan", OrphanAfter: 24 * time.Hour},
+			expectedAction: "Wait",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			plan := NewCleanupPlan([]Decision{test.decision})
+			entry := plan.Entries[0]
+			if entry.Action != test.expectedAction || entry.Policy != test.expectedPolicy || entry.StorageClassName != "test-sc" {
+				t.Fatalf("Expected action %v of policy %q, got %+v", test.expectedAction, test.expectedPolicy, entry)
+			}
+		})
+	}
+}
+
+func TestHoldReplicas(t *testing.T) {
+	data := generators.GeneratePersistentVolumeClaim("data-test-sts-2", constants.TEST_NAMESPACE, "test-sc", nil)
+	wal := generators.GeneratePersistentVolumeClaim("wal-test-sts-2", constants.TEST_NAMESPACE, "test-sc", nil)
+	gracePolicy := &v1alpha1.PVCCleanupPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "grace"},
+		Spec:       v1alpha1.PVCCleanupPolicySpec{Action: v1alpha1.Delete, GracePeriod: &metav1.Duration{Duration: time.Hour}},
+	}
+
+	tests := map[string]struct {
+		decisions       []Decision
+		expectedActions []string
+	}{
+		"Claims of a dangling replica are deleted together": {
+			decisions: []Decision{
+				{PVC: *data, Dangling: true, Replica: "uid/2"},
+				{PVC: *wal, Dangling: true, Replica: "uid/2"},
+			},
+			expectedActions: []string{"Delete", "Delete"},
+		},
+		"Claim waits for a claim of the same replica within its grace period": {
+			decisions: []Decision{
+				{PVC: *data, Dangling: true, Replica: "uid/2"},
+				{PVC: *wal, Dangling: true, Policy: gracePolicy, Replica: "uid/2"},
+			},
+			expectedActions: []string{"Wait", "Wait"},
+		},
+		"Claim waits for a blocked claim of the same replica": {
+			decisions: []Decision{
+				{PVC: *data, Dangling: true, Blocked: "safety check failed", Replica: "uid/2"},
+				{PVC: *wal, Dangling: true, Replica: "uid/2"},
+			},
+			expectedActions: []string{"Blocked", "Wait"},
+		},
+		"Claims of other replicas are not held": {
+			decisions: []Decision{
+				{PVC: *data, Dangling: false, Replica: "uid/1"},
+				{PVC: *wal, Dangling: true, Replica: "uid/2"},
+			},
+			expectedActions: []string{"Keep", "Delete"},
+		},
+		"Claim without a known replica is not held": {
+			decisions: []Decision{
+				{PVC: *data, Dangling: false},
+				{PVC: *wal, Dangling: true},
+			},
+			expectedActions: []string{"Keep", "Delete"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			holdReplicas(test.decisions)
+			for i := range test.decisions {
+				if action := test.decisions[i].Action(); action != test.expectedActions[i] {
+					t.Fatalf("Expected actions %v, got %v for %v", test.expectedActions, action, test.decisions[i].PVC.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestOnePerPVC(t *testing.T) {
+	data := generators.GeneratePersistentVolumeClaim("data-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	data.UID = "data-uid"
+	wal := generators.GeneratePersistentVolumeClaim("wal-test-sts-0", constants.TEST_NAMESPACE, "test-sc", nil)
+	wal.UID = "wal-uid"
+	deletePolicy := &v1alpha1.PVCCleanupPolicy{ObjectMeta: metav1.ObjectMeta{Name: "a-delete"}, Spec: v1alpha1.PVCCleanupPolicySpec{Action: v1alpha1.Delete}}
+	otherDeletePolicy := &v1alpha1.PVCCleanupPolicy{ObjectMeta: metav1.ObjectMeta{Name: "b-delete"}}
+	quarantinePolicy := &v1alpha1.PVCCleanupPolicy{ObjectMeta: metav1.ObjectMeta{Name: "z-quarantine"}, Spec: v1alpha1.PVCCleanupPolicySpec{Action: v1alpha1.Quarantine}}
+
+	tests := map[string]struct {
+		decisions        []Decision
+		expectedPolicies []string
+	}{
+		"PVCs selected by one policy each are all kept": {
+			decisions:        []Decision{{PVC: *data, Policy: deletePolicy}, {PVC: *wal, Policy: quarantinePolicy}},
+			expectedPolicies: []string{"a-delete", "z-quarantine"},
+		},
+		"Most conservative action wins": {
+			decisions:        []Decision{{PVC: *data, Policy: deletePolicy}, {PVC: *data, Policy: quarantinePolicy}},
+			expectedPolicies: []string{"z-quarantine"},
+		},
+		"Lowest policy name wins for the same action": {
+			decisions:        []Decision{{PVC: *data, Policy: otherDeletePolicy}, {PVC: *wal, Policy: deletePolicy}, {PVC: *data, Policy: deletePolicy}},
+			expectedPolicies: []string{"a-delete", "a-delete"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			decisions := onePerPVC(test.decisions)
+			if len(decisions) != len(test.expectedPolicies) {
+				t.Fatalf("Expected policies %v, got %v decisions", test.expectedPolicies, len(decisions))
+			}
+			for i := range decisions {
+				if decisions[i].Policy.Name != test.expectedPolicies[i] {
+					t.Fatalf("Expected policies %v, got %v for %v", test.expectedPolicies, decisions[i].Policy.Name, decisions[i].PVC.Name)
+				}
+			}
+		})
+	}
+}
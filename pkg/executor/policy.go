@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/audit"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
+	"github.com/ksraj123/lister-sa/pkg/danglingpvcs"
+	"github.com/ksraj123/lister-sa/pkg/limits"
+	"github.com/ksraj123/lister-sa/pkg/policy"
+	"github.com/ksraj123/lister-sa/pkg/utils"
+	"github.com/ksraj123/lister-sa/pkg/volumesnapshot"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ExecutePolicy applies the action of the policy to the dangling PVCs of the decisions PlanPolicy took for it and records the counts in its status
+func ExecutePolicy(clientset kubernetes.Interface, cleanerClientset versioned.Interface, dynamicClient dynamic.Interface, ctx context.Context, cleanupPolicy *v1alpha1.PVCCleanupPolicy, decisions []Decision, recorder *audit.Recorder, deleter *limits.Deleter) {
+	if len(decisions) == 0 {
+		fmt.Printf("No StatefulSet PVCs selected by PVCCleanupPolicy %v\n", cleanupPolicy.Name)
+	}
+	status := v1alpha1.PVCCleanupPolicyStatus{Selected: int32(len(decisions))}
+	var statusLock sync.Mutex
+	now := time.Now()
+	for i := range decisions {
+		pvc := &decisions[i].PVC
+		if decisions[i].Blocked != "" {
+			fmt.Printf("Skipping PVC %v in namespace %v, safety check failed, %v\n", pvc.Name, pvc.Namespace, decisions[i].Blocked)
+			continue
+		}
+		if !decisions[i].Dangling {
+			danglingpvcs.UnmarkDangling(clientset, ctx, pvc)
+			continue
+		}
+		status.Dangling++
+		danglingSince := danglingpvcs.MarkDangling(clientset, ctx, pvc, now)
+		if decisions[i].Held != "" {
+			fmt.Printf("Dangling PVC %v in namespace %v is held, %v\n", pvc.Name, pvc.Namespace, decisions[i].Held)
+			continue
+		}
+		if !policy.GracePeriodElapsed(cleanupPolicy, danglingSince, now) {
+			fmt.Printf("Dangling PVC %v in namespace %v is within the grace period of PVCCleanupPolicy %v\n", pvc.Name, pvc.Namespace, cleanupPolicy.Name)
+			continue
+		}
+		lastUsed, found := danglingpvcs.LastUsed(pvc)
+		if !found {
+			lastUsed = danglingSince
+		}
+		if !policy.UnusedForElapsed(cleanupPolicy, lastUsed, now) {
+			fmt.Printf("Dangling PVC %v in namespace %v was used too recently for PVCCleanupPolicy %v, last used %v\n", pvc.Name, pvc.Namespace, cleanupPolicy.Name, lastUsed.UTC().Format(time.RFC3339))
+			continue
+		}
+		deleter.Go(ctx, func(ctx context.Context) {
+			actionStatus := v1alpha1.PVCCleanupPolicyStatus{}
+			applyAction(clientset, dynamicClient, ctx, cleanupPolicy, pvc, &actionStatus, recorder)
+			statusLock.Lock()
+			defer statusLock.Unlock()
+			status.Deleted += actionStatus.Deleted
+			status.Snapshotted += actionStatus.Snapshotted
+			status.Quarantined += actionStatus.Quarantined
+			status.Failed += actionStatus.Failed
+		})
+	}
+	deleter.Wait()
+
+	lastRunTime := metav1.Now()
+	status.LastRunTime = &lastRunTime
+	cleanupPolicy.Status = status
+	// the counts of a stopped run are still recorded
+	ctx, cancel := utils.FinishContext(ctx)
+	defer cancel()
+	_, err := cleanerClientset.PVCCleanerV1alpha1().PVCCleanupPolicies().UpdateStatus(ctx, cleanupPolicy, metav1.UpdateOptions{})
+	if err != nil {
+		fmt.Printf("Could not update status of PVCCleanupPolicy %v, Error = %v\n", cleanupPolicy.Name, err.Error())
+	}
+}
+
+func applyAction(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ctx context.Context, cleanupPolicy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim, status *v1alpha1.PVCCleanupPolicyStatus, recorder *audit.Recorder) {
+	switch policy.Action(cleanupPolicy) {
+	case v1alpha1.Delete:
+		reason := fmt.Sprintf("not mounted by any pod, action %v of PVCCleanupPolicy %v", v1alpha1.Delete, cleanupPolicy.Name)
+		if err := danglingpvcs.DeletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil {
+			fmt.Printf("Error while deleting dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+			status.Failed++
+			return
+		}
+		status.Deleted++
+	case v1alpha1.Quarantine:
+		quarantined, err := danglingpvcs.Quarantine(clientset, ctx, pvc)
+		if err != nil {
+			fmt.Printf("Error while quarantining dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+			status.Failed++
+			return
+		}
+		if quarantined {
+			status.Quarantined++
+		}
+	case v1alpha1.Snapshot:
+		// the PVC is only deleted in a later run, once its snapshot is ready to use
+		exists, ready, err := volumesnapshot.Status(dynamicClient, ctx, pvc)
+		if err != nil {
+			fmt.Printf("Could not get snapshot of dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+			status.Failed++
+			return
+		}
+		if !exists {
+			if err := volumesnapshot.Create(dynamicClient, ctx, pvc, cleanupPolicy.Spec.VolumeSnapshotClassName); err != nil {
+				fmt.Printf("Error while snapshotting dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+				status.Failed++
+				return
+			}
+			fmt.Printf("Snapshot %v of dangling PVC %v in namespace %v created\n", volumesnapshot.Name(pvc), pvc.Name, pvc.Namespace)
+			status.Snapshotted++
+			return
+		}
+		if !ready {
+			fmt.Printf("Snapshot %v of dangling PVC %v in namespace %v is not ready to use yet\n", volumesnapshot.Name(pvc), pvc.Name, pvc.Namespace)
+			return
+		}
+		reason := fmt.Sprintf("not mounted by any pod, action %v of PVCCleanupPolicy %v, snapshot %v ready to use", v1alpha1.Snapshot, cleanupPolicy.Name, volumesnapshot.Name(pvc))
+		if err := danglingpvcs.DeletePVC(clientset, dynamicClient, ctx, pvc, reason, recorder); err != nil {
+			fmt.Printf("Error while deleting dangling PVC %v in namespace %v, Error = %v\n", pvc.Name, pvc.Namespace, err.Error())
+			status.Failed++
+			return
+		}
+		status.Deleted++
+	}
+}
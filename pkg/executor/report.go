@@ -0,0 +1,210 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/listers"
+	"github.com/ksraj123/lister-sa/pkg/provisioners"
+
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReclaimableVolume is a dangling StatefulSet PVC and the storage it holds
+type ReclaimableVolume struct {
+	Namespace        string `json:"namespace"`
+	Name             string `json:"name"`
+	StorageClassName string `json:"storageClassName"`
+	VolumeName       string `json:"volumeName,omitempty"`
+	// Node is set for local PVs that are pinned to a node
+	Node     string            `json:"node,omitempty"`
+	Capacity resource.Quantity `json:"capacity"`
+	// DeletionEnabled is true if the StorageClass of the PVC has the delete-dangling-pvc annotation set
+	DeletionEnabled bool `json:"deletionEnabled"`
+}
+
+// Totals is the number of PVCs and the storage they hold
+type Totals struct {
+	PVCs     int               `json:"pvcs"`
+	Capacity resource.Quantity `json:"capacity"`
+}
+
+func (t *Totals) add(volume ReclaimableVolume) {
+	t.PVCs++
+	t.Capacity.Add(volume.Capacity)
+}
+
+// DroppedTemplateVolume is a PVC of a volumeClaimTemplate that was dropped from its live StatefulSet, it is not dangling
+// but nothing uses it either
+type DroppedTemplateVolume struct {
+	ReclaimableVolume `json:",inline"`
+	Template          string `json:"template"`
+}
+
+// HeldVolume is a dangling StatefulSet PVC that is not deleted for now, because the safety check of its namespace failed
+// or another claim of its replica is not deleted
+type HeldVolume struct {
+	ReclaimableVolume `json:",inline"`
+	Reason            string `json:"reason"`
+}
+
+// CapacityReport is the storage that can be reclaimed by deleting dangling PVCs
+type CapacityReport struct {
+	Volumes []ReclaimableVolume `json:"volumes"`
+	// DroppedTemplates are not counted in the totals, they are only deleted once their StatefulSet is
+	DroppedTemplates []DroppedTemplateVolume `json:"droppedTemplates"`
+	// Held are not counted in the totals either
+	Held []HeldVolume `json:"held"`
+	// Total, ByNamespace, ByStorageClass and ByNode are the totals of the Volumes, set by Summarize. PVCs that are not
+	// bound to a local PV are grouped under an empty node name
+	Total          Totals             `json:"total"`
+	ByNamespace    map[string]*Totals `json:"byNamespace"`
+	ByStorageClass map[string]*Totals `json:"byStorageClass"`
+	ByNode         map[string]*Totals `json:"byNode"`
+}
+
+// NewCapacityReport sums up the storage held by the dangling StatefulSet PVCs of the decisions of a run, see Plan. PVCs
+// that are blocked by the safety check or held by another claim of their replica are listed apart and not counted, nor
+// are orphans and replicas that lost their node
+func NewCapacityReport(clientset kubernetes.Interface, ctx context.Context, decisions []Decision) *CapacityReport {
+	storageClassesMap := make(map[string]*StorageV1.StorageClass)
+	storageClasses := listers.ListAllStorageClasses(clientset, ctx)
+	for i := range storageClasses {
+		storageClassesMap[storageClasses[i].Name] = &storageClasses[i]
+	}
+	persistentVolumes := make(map[string]*v1.PersistentVolume)
+	allPvs := listers.ListAllPersistentVolumes(clientset, ctx)
+	for i := range allPvs {
+		persistentVolumes[allPvs[i].Name] = &allPvs[i]
+	}
+
+	report := &CapacityReport{Volumes: []ReclaimableVolume{}, DroppedTemplates: []DroppedTemplateVolume{}, Held: []HeldVolume{}}
+	for i := range decisions {
+		decision := &decisions[i]
+		pvc := &decision.PVC
+		if decision.Kind != "" || pvc.Spec.StorageClassName == nil {
+			continue
+		}
+		storageclass, exists := storageClassesMap[*pvc.Spec.StorageClassName]
+		if !exists {
+			continue
+		}
+		volume := NewReclaimableVolume(pvc, persistentVolumes[pvc.Spec.VolumeName], storageclass)
+		switch {
+		case decision.Blocked != "":
+			report.Held = append(report.Held, HeldVolume{ReclaimableVolume: volume, Reason: "safety check failed, " + decision.Blocked})
+		case decision.Held != "":
+			report.Held = append(report.Held, HeldVolume{ReclaimableVolume: volume, Reason: decision.Held})
+		case decision.Dangling:
+			report.Volumes = append(report.Volumes, volume)
+		case decision.DroppedTemplate != "":
+			report.DroppedTemplates = append(report.DroppedTemplates, DroppedTemplateVolume{ReclaimableVolume: volume, Template: decision.DroppedTemplate})
+		}
+	}
+	report.Summarize()
+	return report
+}
+
+// The capacity of the bound PV is used if there is one, otherwise the capacity or requested size of the PVC
+func NewReclaimableVolume(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume, storageclass *StorageV1.StorageClass) ReclaimableVolume {
+	volume := ReclaimableVolume{
+		Namespace:        pvc.Namespace,
+		Name:             pvc.Name,
+		StorageClassName: storageclass.Name,
+		VolumeName:       pvc.Spec.VolumeName,
+		DeletionEnabled:  storageclass.Annotations[constants.STORAGE_CLASS_ANNOTATION] == "true",
+	}
+	if pv != nil {
+		volume.Node = provisioners.For(storageclass.Provisioner).Node(pv)
+		if capacity, exists := pv.Spec.Capacity[v1.ResourceStorage]; exists {
+			volume.Capacity = capacity
+			return volume
+		}
+	}
+	if capacity, exists := pvc.Status.Capacity[v1.ResourceStorage]; exists {
+		volume.Capacity = capacity
+	} else if capacity, exists := pvc.Spec.Resources.Requests[v1.ResourceStorage]; exists {
+		volume.Capacity = capacity
+	}
+	return volume
+}
+
+// Summarize computes the totals of the Volumes, overall and per namespace, StorageClass and node
+func (r *CapacityReport) Summarize() {
+	r.Total = Totals{}
+	for _, volume := range r.Volumes {
+		r.Total.add(volume)
+	}
+	r.ByNamespace = r.groupBy(func(volume ReclaimableVolume) string { return volume.Namespace })
+	r.ByStorageClass = r.groupBy(func(volume ReclaimableVolume) string { return volume.StorageClassName })
+	r.ByNode = r.groupBy(func(volume ReclaimableVolume) string { return volume.Node })
+}
+
+func (r *CapacityReport) groupBy(key func(ReclaimableVolume) string) map[string]*Totals {
+	groups := make(map[string]*Totals)
+	for _, volume := range r.Volumes {
+		totals, exists := groups[key(volume)]
+		if !exists {
+			totals = &Totals{}
+			groups[key(volume)] = totals
+		}
+		totals.add(volume)
+	}
+	return groups
+}
+
+// PrintTable writes the report as tables of the dangling PVCs and their totals per namespace, StorageClass and node
+func (r *CapacityReport) PrintTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tPVC\tSTORAGECLASS\tVOLUME\tNODE\tCAPACITY\tDELETION ENABLED")
+	for _, volume := range r.Volumes {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", volume.Namespace, volume.Name, volume.StorageClassName, orNone(volume.VolumeName), orNone(volume.Node), volume.Capacity.String(), volume.DeletionEnabled)
+	}
+	fmt.Fprintln(w)
+	printTotals(w, "NAMESPACE", r.ByNamespace)
+	printTotals(w, "STORAGECLASS", r.ByStorageClass)
+	printTotals(w, "NODE", r.ByNode)
+	fmt.Fprintf(w, "TOTAL\t%v\t%v\n", r.Total.PVCs, r.Total.Capacity.String())
+	if len(r.DroppedTemplates) != 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "NAMESPACE\tPVC\tDROPPED TEMPLATE\tSTORAGECLASS\tCAPACITY")
+		for _, volume := range r.DroppedTemplates {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", volume.Namespace, volume.Name, volume.Template, volume.StorageClassName, volume.Capacity.String())
+		}
+	}
+	if len(r.Held) != 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "NAMESPACE\tPVC\tSTORAGECLASS\tCAPACITY\tHELD BECAUSE")
+		for _, volume := range r.Held {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", volume.Namespace, volume.Name, volume.StorageClassName, volume.Capacity.String(), volume.Reason)
+		}
+	}
+	w.Flush()
+}
+
+func printTotals(w io.Writer, title string, groups map[string]*Totals) {
+	var keys []string
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "%v\tPVCS\tCAPACITY\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", orNone(key), groups[key].PVCs, groups[key].Capacity.String())
+	}
+	fmt.Fprintln(w)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
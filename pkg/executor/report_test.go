@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func generateLocalPV(name string, node string, capacity string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)},
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchExpressions: []v1.NodeSelectorRequirement{{
+							Key:      "kubernetes.io/hostname",
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{node},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestNewReclaimableVolume(t *testing.T) {
+	storageClass := generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, nil, "openebs.io/local")
+	pvc := generators.GeneratePersistentVolumeClaim("pvc-test-sts-0", constants.TEST_NAMESPACE, storageClass.Name, nil)
+
+	tests := map[string]struct {
+		pv               *v1.PersistentVolume
+		expectedCapacity string
+		expectedNode     string
+	}{
+		"Capacity of the bound PV": {
+			pv:               generateLocalPV("pv-1", "node-1", "5Gi"),
+			expectedCapacity: "5Gi",
+			expectedNode:     "node-1",
+		},
+		"Requested size of an unbound PVC": {
+			expectedCapacity: "1Gi",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			volume := NewReclaimableVolume(pvc, test.pv, storageClass)
+			if volume.Capacity.String() != test.expectedCapacity || volume.Node != test.expectedNode || !volume.DeletionEnabled {
+				t.Fatalf("Expected capacity %v on node %q with deletion enabled, got %+v", test.expectedCapacity, test.expectedNode, volume)
+			}
+		})
+	}
+}
+
+func TestCapacityReport(t *testing.T) {
+	report := &CapacityReport{
+		Volumes: []ReclaimableVolume{
+			{Namespace: "default", Name: "pvc-a-0", StorageClassName: "hostpath", Node: "node-1", Capacity: resource.MustParse("1Gi")},
+			{Namespace: "default", Name: "pvc-a-1", StorageClassName: "hostpath", Node: "node-2", Capacity: resource.MustParse("1Gi")},
+			{Namespace: "mongo", Name: "data-b-0", StorageClassName: "lvm", Node: "node-1", Capacity: resource.MustParse("10Gi")},
+		},
+	}
+	report.Summarize()
+
+	if total := report.Total; total.PVCs != 3 || total.Capacity.String() != "12Gi" {
+		t.Fatalf("Expected 3 PVCs holding 12Gi, got %v PVCs holding %v", total.PVCs, total.Capacity.String())
+	}
+	if byNamespace := report.ByNamespace; byNamespace["default"].Capacity.String() != "2Gi" || byNamespace["mongo"].PVCs != 1 {
+		t.Fatalf("Totals per namespace computed incorrectly")
+	}
+	if byNode := report.ByNode; byNode["node-1"].Capacity.String() != "11Gi" || byNode["node-2"].PVCs != 1 {
+		t.Fatalf("Totals per node computed incorrectly")
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"total":{"pvcs":3,"capacity":"12Gi"}`, `"byStorageClass":{"hostpath":{"pvcs":2,"capacity":"2Gi"}`, `"byNode":{"node-1"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Fatalf("Expected %v in report json, got %v", expected, string(data))
+		}
+	}
+
+	out := &bytes.Buffer{}
+	report.PrintTable(out)
+	for _, expected := range []string{"data-b-0", "STORAGECLASS", "12Gi"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected %q in report table, got\n%v", expected, out.String())
+		}
+	}
+}
+
+func TestNewCapacityReport(t *testing.T) {
+	storageClass := generators.GenerateStorageClass("test-sc", map[string]string{constants.STORAGE_CLASS_ANNOTATION: "true"}, nil, constants.LVM_PROVISIONER)
+	clientset := fake.NewSimpleClientset(storageClass)
+	pvc := func(name string) v1.PersistentVolumeClaim {
+		return *generators.GeneratePersistentVolumeClaim(name, constants.TEST_NAMESPACE, storageClass.Name, nil)
+	}
+
+	tests := map[string]struct {
+		decision                 Decision
+		expectedVolumes          int
+		expectedDroppedTemplates int
+		expectedHeld             string
+	}{
+		"Dangling PVC is counted": {
+			decision:        Decision{PVC: pvc("pvc-test-sts-1"), Dangling: true},
+			expectedVolumes: 1,
+		},
+		"Mounted PVC is not reported": {
+			decision: Decision{PVC: pvc("pvc-test-sts-0")},
+		},
+		"Blocked PVC is listed apart": {
+			decision:     Decision{PVC: pvc("pvc-test-sts-1"), Dangling: true, Blocked: "every PVC of the namespace is dangling"},
+			expectedHeld: "safety check failed, every PVC of the namespace is dangling",
+		},
+		"Held PVC is listed apart": {
+			decision:     Decision{PVC: pvc("data-test-sts-1"), Dangling: true, Held: "claim wal-test-sts-1 of the same replica is not deleted, its action is Keep"},
+			expectedHeld: "claim wal-test-sts-1 of the same replica is not deleted, its action is Keep",
+		},
+		"PVC of a dropped template is listed apart": {
+			decision:                 Decision{PVC: pvc("old-test-sts-0"), DroppedTemplate: "old"},
+			expectedDroppedTemplates: 1,
+		},
+		"Orphan is not reported": {
+			decision: Decision{PVC: pvc("pvc-job"), Dangling: true, Kind: "Orphan"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			report := NewCapacityReport(clientset, context.TODO(), []Decision{test.decision})
+			if len(report.Volumes) != test.expectedVolumes || report.Total.PVCs != test.expectedVolumes {
+				t.Fatalf("Expected %v reclaimable volumes, got %+v", test.expectedVolumes, report.Volumes)
+			}
+			if len(report.DroppedTemplates) != test.expectedDroppedTemplates {
+				t.Fatalf("Expected %v volumes of dropped templates, got %+v", test.expectedDroppedTemplates, report.DroppedTemplates)
+			}
+			if (test.expectedHeld == "") != (len(report.Held) == 0) || (len(report.Held) != 0 && report.Held[0].Reason != test.expectedHeld) {
+				t.Fatalf("Expected held because %q, got %+v", test.expectedHeld, report.Held)
+			}
+		})
+	}
+}
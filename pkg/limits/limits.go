@@ -0,0 +1,183 @@
+package limits
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/utils"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Limits pace the deletions of a run and stop it from deleting too many PVCs at once
+type Limits struct {
+	// Concurrency is the number of deletions in flight at a time
+	Concurrency int
+	// QPS and Burst limit the rate deletions are started at, no limit if QPS is 0
+	QPS   float64
+	Burst int
+	// MaxDeletions and MaxDeletionsPercent of the selected PVCs abort the run before anything is deleted, 0 disables them
+	MaxDeletions        int
+	MaxDeletionsPercent int
+	// Force acts on the PVCs of namespaces that failed the safety check
+	Force bool
+}
+
+// Defaults delete one PVC at a time without any other limit, as before limits existed
+func Defaults() Limits {
+	return Limits{Concurrency: 1, Burst: 1}
+}
+
+// FromEnv returns the defaults overridden by the limits set in the environment
+func FromEnv() (Limits, error) {
+	limits := Defaults()
+	for envVar, value := range map[string]*int{
+		constants.DELETION_CONCURRENCY_ENV_VAR:  &limits.Concurrency,
+		constants.DELETION_BURST_ENV_VAR:        &limits.Burst,
+		constants.MAX_DELETIONS_ENV_VAR:         &limits.MaxDeletions,
+		constants.MAX_DELETIONS_PERCENT_ENV_VAR: &limits.MaxDeletionsPercent,
+	} {
+		if env, exists := os.LookupEnv(envVar); exists {
+			parsed, err := strconv.Atoi(env)
+			if err != nil {
+				return limits, fmt.Errorf("invalid %v %q, %v", envVar, env, err.Error())
+			}
+			*value = parsed
+		}
+	}
+	if env, exists := os.LookupEnv(constants.DELETION_QPS_ENV_VAR); exists {
+		qps, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return limits, fmt.Errorf("invalid %v %q, %v", constants.DELETION_QPS_ENV_VAR, env, err.Error())
+		}
+		limits.QPS = qps
+	}
+	return limits, limits.Validate()
+}
+
+// AddFlags adds a flag for each limit, defaulting to the current value
+func (l *Limits) AddFlags(flags *flag.FlagSet) {
+	flags.IntVar(&l.Concurrency, "deletion-concurrency", l.Concurrency, "number of PVCs deleted at a time, defaults to the "+constants.DELETION_CONCURRENCY_ENV_VAR+" environment variable")
+	flags.Float64Var(&l.QPS, "deletion-qps", l.QPS, "deletions started per second, 0 for no limit, defaults to the "+constants.DELETION_QPS_ENV_VAR+" environment variable")
+	flags.IntVar(&l.Burst, "deletion-burst", l.Burst, "deletions started at once above --deletion-qps, defaults to the "+constants.DELETION_BURST_ENV_VAR+" environment variable")
+	flags.IntVar(&l.MaxDeletions, "max-deletions", l.MaxDeletions, "abort the run if more PVCs would be deleted, 0 for no limit, defaults to the "+constants.MAX_DELETIONS_ENV_VAR+" environment variable")
+	flags.BoolVar(&l.Force, "force", l.Force, "act on the PVCs of namespaces that failed the safety check, where a failed list or most PVCs being dangling hint at a misconfiguration")
+	flags.IntVar(&l.MaxDeletionsPercent, "max-deletions-percent", l.MaxDeletionsPercent, "abort the run if a larger percentage of the selected PVCs would be deleted, 0 for no limit, defaults to the "+constants.MAX_DELETIONS_PERCENT_ENV_VAR+" environment variable")
+}
+
+func (l Limits) Validate() error {
+	if l.Concurrency < 1 {
+		return fmt.Errorf("deletion concurrency must be at least 1, got %v", l.Concurrency)
+	}
+	if l.QPS < 0 {
+		return fmt.Errorf("deletion qps must not be negative, got %v", l.QPS)
+	}
+	if l.QPS > 0 && l.Burst < 1 {
+		return fmt.Errorf("deletion burst must be at least 1, got %v", l.Burst)
+	}
+	if l.MaxDeletions < 0 {
+		return fmt.Errorf("max deletions must not be negative, got %v", l.MaxDeletions)
+	}
+	if l.MaxDeletionsPercent < 0 || l.MaxDeletionsPercent > 100 {
+		return fmt.Errorf("max deletions percent must be between 0 and 100, got %v", l.MaxDeletionsPercent)
+	}
+	return nil
+}
+
+// Check returns an error if deleting that many of the selected PVCs goes over the maximum number or percentage of deletions
+func (l Limits) Check(deletions int, selected int) error {
+	if l.MaxDeletions > 0 && deletions > l.MaxDeletions {
+		return fmt.Errorf("%v PVCs would be deleted, more than the maximum of %v deletions per run", deletions, l.MaxDeletions)
+	}
+	if l.MaxDeletionsPercent > 0 && selected > 0 && deletions*100 > l.MaxDeletionsPercent*selected {
+		return fmt.Errorf("%v of %v selected PVCs would be deleted, more than the maximum of %v%% per run", deletions, selected, l.MaxDeletionsPercent)
+	}
+	return nil
+}
+
+// Deleter runs deletions concurrently, at most Concurrency at a time and started no faster than QPS allows.
+// Once the context of the run is done no further deletion is started, the ones already started are finished
+type Deleter struct {
+	limiter flowcontrol.RateLimiter
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	started int
+	stopped int
+}
+
+// A Concurrency below 1, as in the zero Limits, deletes one PVC at a time
+func (l Limits) NewDeleter() *Deleter {
+	concurrency := l.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	deleter := &Deleter{slots: make(chan struct{}, concurrency)}
+	if l.QPS > 0 {
+		deleter.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(l.QPS), l.Burst)
+	}
+	return deleter
+}
+
+// Go blocks until the deletion can be started and runs it in the background, it returns false without running the
+// deletion if ctx is done first. The deletion gets a context that is not cancelled with ctx, so that it is not
+// abandoned halfway, but that is cancelled FINISH_TIMEOUT after ctx is done
+func (d *Deleter) Go(ctx context.Context, deletion func(ctx context.Context)) bool {
+	if !d.acquire(ctx) {
+		d.lock.Lock()
+		d.stopped++
+		d.lock.Unlock()
+		return false
+	}
+	d.lock.Lock()
+	d.started++
+	d.lock.Unlock()
+	d.wg.Add(1)
+	go func() {
+		defer func() {
+			<-d.slots
+			d.wg.Done()
+		}()
+		deletionCtx, cancel := utils.GraceContext(ctx)
+		defer cancel()
+		deletion(deletionCtx)
+	}()
+	return true
+}
+
+func (d *Deleter) acquire(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if d.limiter != nil && d.limiter.Wait(ctx) != nil {
+		return false
+	}
+	select {
+	case d.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Started and Stopped are the number of deletions that were started and that were not because the run was stopped
+func (d *Deleter) Started() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.started
+}
+
+func (d *Deleter) Stopped() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.stopped
+}
+
+// Wait returns once all started deletions are done
+func (d *Deleter) Wait() {
+	d.wg.Wait()
+}
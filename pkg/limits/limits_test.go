@@ -0,0 +1,130 @@
+package limits
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		limits      Limits
+		deletions   int
+		selected    int
+		expectError bool
+	}{
+		"No maximum allows any number of deletions": {
+			limits:    Defaults(),
+			deletions: 100,
+			selected:  100,
+		},
+		"Deletions up to the maximum are allowed": {
+			limits:    Limits{MaxDeletions: 10},
+			deletions: 10,
+			selected:  100,
+		},
+		"Deletions above the maximum abort the run": {
+			limits:      Limits{MaxDeletions: 10},
+			deletions:   11,
+			selected:    100,
+			expectError: true,
+		},
+		"Deletions up to the maximum percentage are allowed": {
+			limits:    Limits{MaxDeletionsPercent: 50},
+			deletions: 5,
+			selected:  10,
+		},
+		"Deletions above the maximum percentage abort the run": {
+			limits:      Limits{MaxDeletionsPercent: 50},
+			deletions:   6,
+			selected:    10,
+			expectError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.limits.Check(test.deletions, test.selected)
+			if (err != nil) != test.expectError {
+				t.Fatalf("Expected error %v for %v of %v deletions, got %v", test.expectError, test.deletions, test.selected, err)
+			}
+		})
+	}
+}
+
+func TestDeleterConcurrency(t *testing.T) {
+	tests := map[string]struct {
+		concurrency int
+		expectedMax int
+	}{
+		"Zero concurrency deletes one at a time":   {concurrency: 0, expectedMax: 1},
+		"Deletions are bounded by the concurrency": {concurrency: 3, expectedMax: 3},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			deleter := Limits{Concurrency: test.concurrency}.NewDeleter()
+			var lock sync.Mutex
+			inFlight, maxInFlight := 0, 0
+			for i := 0; i < 20; i++ {
+				deleter.Go(context.Background(), func(ctx context.Context) {
+					lock.Lock()
+					inFlight++
+					if inFlight > maxInFlight {
+						maxInFlight = inFlight
+					}
+					lock.Unlock()
+					lock.Lock()
+					inFlight--
+					lock.Unlock()
+				})
+			}
+			deleter.Wait()
+			if maxInFlight > test.expectedMax {
+				t.Fatalf("Expected at most %v deletions in flight, got %v", test.expectedMax, maxInFlight)
+			}
+		})
+	}
+}
+
+func TestDeleterStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deleter := Defaults().NewDeleter()
+	deleted := 0
+	for i := 0; i < 5; i++ {
+		if i == 2 {
+			cancel()
+		}
+		deleter.Go(ctx, func(ctx context.Context) {
+			if ctx.Err() != nil {
+				t.Errorf("Expected started deletion to get a context that is not done")
+			}
+			deleted++
+		})
+		deleter.Wait()
+	}
+	if deleted != 2 || deleter.Started() != 2 || deleter.Stopped() != 3 {
+		t.Fatalf("Expected 2 deletions started and 3 stopped, got %v deleted, %v started and %v stopped", deleted, deleter.Started(), deleter.Stopped())
+	}
+}
+
+func TestDeleterGrace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deleter := Defaults().NewDeleter()
+	var deletionCtx context.Context
+	deleter.Go(ctx, func(ctx context.Context) {
+		deletionCtx = ctx
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Errorf("Expected the deletion to finish after the run was stopped, got %v", ctx.Err())
+		}
+	})
+	deleter.Wait()
+	if deletionCtx.Err() == nil {
+		t.Fatalf("Expected the context of a finished deletion to be released")
+	}
+}
@@ -4,38 +4,155 @@ import (
 	"context"
 	"fmt"
 
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/client/clientset/versioned"
 	AppsV1 "k8s.io/api/apps/v1"
+	BatchV1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	StorageV1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
-func ListAllStatefulSets(clientset *kubernetes.Clientset, ctx context.Context, namespace string) []AppsV1.StatefulSet {
-	allStatefulsets, errAllSts := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+func ListAllStatefulSets(clientset kubernetes.Interface, ctx context.Context, namespace string) []AppsV1.StatefulSet {
+	allStatefulsets, errAllSts := ListStatefulSets(clientset, ctx, namespace)
 	if errAllSts != nil {
 		fmt.Printf("error %s, getting PVCs\n", errAllSts.Error())
 	}
-	return allStatefulsets.Items
+	return allStatefulsets
 }
 
-func ListAllStorageClasses(clientset *kubernetes.Clientset, ctx context.Context) []StorageV1.StorageClass {
+// ListStatefulSets, ListPods and ListPersistentVolumeClaims return the error of the list call, so that a failed list
+// is not mistaken for an empty one
+func ListStatefulSets(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]AppsV1.StatefulSet, error) {
+	statefulsets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return statefulsets.Items, nil
+}
+
+func ListPods(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]v1.Pod, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+func ListPersistentVolumeClaims(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]v1.PersistentVolumeClaim, error) {
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pvcs.Items, nil
+}
+
+// ListNodes, ListPersistentVolumes and ListEvents return the error of the list call too, an empty node list would make every node look lost
+func ListNodes(clientset kubernetes.Interface, ctx context.Context) ([]v1.Node, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+func ListPersistentVolumes(clientset kubernetes.Interface, ctx context.Context) ([]v1.PersistentVolume, error) {
+	pvs, err := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pvs.Items, nil
+}
+
+func ListEvents(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]v1.Event, error) {
+	events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, nil
+}
+
+// ListDeployments, ListReplicaSets, ListDaemonSets, ListJobs and ListCronJobs return the error of the list call too, a
+// failed list would make the PVCs their pod templates reference look orphaned
+func ListDeployments(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]AppsV1.Deployment, error) {
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return deployments.Items, nil
+}
+
+func ListReplicaSets(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]AppsV1.ReplicaSet, error) {
+	replicasets, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return replicasets.Items, nil
+}
+
+func ListDaemonSets(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]AppsV1.DaemonSet, error) {
+	daemonsets, err := clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return daemonsets.Items, nil
+}
+
+func ListJobs(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]BatchV1.Job, error) {
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+func ListCronJobs(clientset kubernetes.Interface, ctx context.Context, namespace string) ([]BatchV1.CronJob, error) {
+	cronjobs, err := clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return cronjobs.Items, nil
+}
+
+// lists the pods selected by the selector of the statefulset
+func ListPodsOfStatefulSet(clientset kubernetes.Interface, ctx context.Context, namespace string, statefulset *AppsV1.StatefulSet) []v1.Pod {
+	labelSelectorString := labels.SelectorFromSet(statefulset.Spec.Selector.MatchLabels).String()
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelectorString})
+	if err != nil {
+		fmt.Printf("Could not get Pods of label %v in namespace %v, Error = %v\n", labelSelectorString, namespace, err.Error())
+		return nil
+	}
+	return pods.Items
+}
+
+func ListAllPods(clientset kubernetes.Interface, ctx context.Context, namespace string) []v1.Pod {
+	allPods, errPods := ListPods(clientset, ctx, namespace)
+	if errPods != nil {
+		fmt.Printf("error %s, getting Pods\n", errPods.Error())
+	}
+	return allPods
+}
+
+func ListAllStorageClasses(clientset kubernetes.Interface, ctx context.Context) []StorageV1.StorageClass {
 	allSc, errSc := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
 	if errSc != nil {
 		fmt.Printf("error %s, getting Storage Classes\n", errSc.Error())
+		return nil
 	}
 	return allSc.Items
 }
 
-func ListAllPersistentVolumeClaims(clientset *kubernetes.Clientset, ctx context.Context, namespace string) []v1.PersistentVolumeClaim {
-	allPvcs, errPVC := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+func ListAllPersistentVolumeClaims(clientset kubernetes.Interface, ctx context.Context, namespace string) []v1.PersistentVolumeClaim {
+	allPvcs, errPVC := ListPersistentVolumeClaims(clientset, ctx, namespace)
 	if errPVC != nil {
 		fmt.Printf("error %s, getting PVCs\n", errPVC.Error())
 	}
-	return allPvcs.Items
+	return allPvcs
 }
 
-func ListPVCsOfStorageClass(clientset *kubernetes.Clientset, ctx context.Context, namespace string, storageclasses []*StorageV1.StorageClass) []v1.PersistentVolumeClaim {
+func ListPVCsOfStorageClass(clientset kubernetes.Interface, ctx context.Context, namespace string, storageclasses []*StorageV1.StorageClass) []v1.PersistentVolumeClaim {
 	allPvcs := ListAllPersistentVolumeClaims(clientset, ctx, namespace)
 	var openebsPvcs []v1.PersistentVolumeClaim
 	for _, pvc := range allPvcs {
@@ -51,15 +168,48 @@ func ListPVCsOfStorageClass(clientset *kubernetes.Clientset, ctx context.Context
 }
 
 // retuns list of storage classes that have an provisioner among the provided provisioners and have the annotation set
-func ListProvisionerStorageClassesWithAnnotation(clientset *kubernetes.Clientset, ctx context.Context, provisioners []string, annotation string) []*StorageV1.StorageClass {
+func ListProvisionerStorageClassesWithAnnotation(clientset kubernetes.Interface, ctx context.Context, provisioners []string, annotation string) []*StorageV1.StorageClass {
+	var openEbsStorageClasses []*StorageV1.StorageClass
+	for _, storageclass := range ListProvisionerStorageClasses(clientset, ctx, provisioners) {
+		if storageclass.Annotations[annotation] == "true" {
+			openEbsStorageClasses = append(openEbsStorageClasses, storageclass)
+		}
+	}
+	return openEbsStorageClasses
+}
+
+// retuns list of storage classes that have an provisioner among the provided provisioners
+func ListProvisionerStorageClasses(clientset kubernetes.Interface, ctx context.Context, provisioners []string) []*StorageV1.StorageClass {
 	allSc := ListAllStorageClasses(clientset, ctx)
 	var openEbsStorageClasses []*StorageV1.StorageClass
-	for _, storageclass := range allSc {
+	for i := range allSc {
 		for _, openEbsProvisioner := range provisioners {
-			if storageclass.Provisioner == openEbsProvisioner && storageclass.Annotations[annotation] == "true" {
-				openEbsStorageClasses = append(openEbsStorageClasses, &storageclass)
+			if allSc[i].Provisioner == openEbsProvisioner {
+				openEbsStorageClasses = append(openEbsStorageClasses, &allSc[i])
 			}
 		}
 	}
 	return openEbsStorageClasses
 }
+
+// Without a cleanerClientset there are no policies, as if the CRD was not installed
+func ListAllCleanupPolicies(cleanerClientset versioned.Interface, ctx context.Context) []v1alpha1.PVCCleanupPolicy {
+	if cleanerClientset == nil {
+		return nil
+	}
+	allPolicies, errPolicies := cleanerClientset.PVCCleanerV1alpha1().PVCCleanupPolicies().List(ctx, metav1.ListOptions{})
+	if errPolicies != nil {
+		fmt.Printf("error %s, getting PVC Cleanup Policies\n", errPolicies.Error())
+		return nil
+	}
+	return allPolicies.Items
+}
+
+func ListAllPersistentVolumes(clientset kubernetes.Interface, ctx context.Context) []v1.PersistentVolume {
+	allPvs, errPV := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if errPV != nil {
+		fmt.Printf("error %s, getting PVs\n", errPV.Error())
+		return nil
+	}
+	return allPvs.Items
+}
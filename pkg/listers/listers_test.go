@@ -12,7 +12,10 @@ import (
 	CoreV1 "k8s.io/api/core/v1"
 	StorageV1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	envtest "sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
@@ -248,3 +251,31 @@ func TestListProvisionerStorageClassesWithAnnotation(t *testing.T) {
 		})
 	}
 }
+
+func TestListingErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("list failed")
+	})
+	statefulset := generators.GenerateStatefulSet("test-sts", constants.TEST_NAMESPACE, 1, map[string]string{"role": "test"}, "test-sc")
+
+	tests := map[string]func() int{
+		"Pods of a statefulset": func() int {
+			return len(ListPodsOfStatefulSet(clientset, context.TODO(), constants.TEST_NAMESPACE, statefulset))
+		},
+		"Storage classes": func() int {
+			return len(ListAllStorageClasses(clientset, context.TODO()))
+		},
+		"Persistent volumes": func() int {
+			return len(ListAllPersistentVolumes(clientset, context.TODO()))
+		},
+	}
+
+	for name, list := range tests {
+		t.Run(name, func(t *testing.T) {
+			if listed := list(); listed != 0 {
+				t.Fatalf("Expected nothing listed when listing fails, got %v", listed)
+			}
+		})
+	}
+}
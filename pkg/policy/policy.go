@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/engine"
+	v1 "k8s.io/api/core/v1"
+	StorageV1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Validate checks the parts of a policy that the CRD schema can not, it returns an error describing the first problem found
+func Validate(policy *v1alpha1.PVCCleanupPolicy) error {
+	switch Action(policy) {
+	case v1alpha1.Delete, v1alpha1.Snapshot, v1alpha1.Quarantine:
+	default:
+		return fmt.Errorf("unknown action %v", policy.Spec.Action)
+	}
+	if policy.Spec.GracePeriod != nil && policy.Spec.GracePeriod.Duration < 0 {
+		return fmt.Errorf("grace period %v is negative", policy.Spec.GracePeriod.Duration)
+	}
+	if policy.Spec.UnusedFor != nil && policy.Spec.UnusedFor.Duration < 0 {
+		return fmt.Errorf("unused for %v is negative", policy.Spec.UnusedFor.Duration)
+	}
+	selectors := map[string]*metav1.LabelSelector{
+		"storageClassSelector": policy.Spec.StorageClassSelector,
+		"namespaceSelector":    policy.Spec.NamespaceSelector,
+		"statefulSetSelector":  policy.Spec.StatefulSetSelector,
+	}
+	for name, selector := range selectors {
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			return fmt.Errorf("invalid %v, %v", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Action returns the action of the policy, defaulting to Delete
+func Action(policy *v1alpha1.PVCCleanupPolicy) v1alpha1.CleanupAction {
+	if policy.Spec.Action == "" {
+		return v1alpha1.Delete
+	}
+	return policy.Spec.Action
+}
+
+// conservative ranks the actions from the least to the most destructive
+var conservative = map[v1alpha1.CleanupAction]int{v1alpha1.Quarantine: 0, v1alpha1.Snapshot: 1, v1alpha1.Delete: 2}
+
+// Precedes reports whether policy a wins over policy b for a PVC selected by both: the most conservative action wins,
+// Quarantine over Snapshot over Delete, then the policy with the lowest name
+func Precedes(a *v1alpha1.PVCCleanupPolicy, b *v1alpha1.PVCCleanupPolicy) bool {
+	if conservative[Action(a)] != conservative[Action(b)] {
+		return conservative[Action(a)] < conservative[Action(b)]
+	}
+	return a.Name < b.Name
+}
+
+// nil selectors select everything, unlike metav1.LabelSelectorAsSelector which selects nothing for nil
+func matches(selector *metav1.LabelSelector, objectLabels map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return labelSelector.Matches(labels.Set(objectLabels))
+}
+
+func SelectsNamespace(policy *v1alpha1.PVCCleanupPolicy, namespace *v1.Namespace) bool {
+	return matches(policy.Spec.NamespaceSelector, namespace.Labels)
+}
+
+func SelectsStorageClass(policy *v1alpha1.PVCCleanupPolicy, storageclass *StorageV1.StorageClass) bool {
+	return matches(policy.Spec.StorageClassSelector, storageclass.Labels)
+}
+
+// SelectsPVC decides if the PVC is a StatefulSet PVC covered by the policy. Without a statefulSetSelector the
+// lineage or the sts-pvc-selector parameter of the StorageClass is used, same as statefulsetpvcs.GetStatefulSetPVCs
+func SelectsPVC(policy *v1alpha1.PVCCleanupPolicy, pvc *v1.PersistentVolumeClaim, storageclass *StorageV1.StorageClass) bool {
+	if policy.Spec.StatefulSetSelector != nil {
+		return matches(policy.Spec.StatefulSetSelector, pvc.Labels)
+	}
+	return engine.IsStatefulSetPVC(pvc, storageclass)
+}
+
+// GracePeriodElapsed reports if a PVC that has been dangling since the given time is due for the policy action
+func GracePeriodElapsed(policy *v1alpha1.PVCCleanupPolicy, danglingSince time.Time, now time.Time) bool {
+	if policy.Spec.GracePeriod == nil {
+		return true
+	}
+	return !now.Before(danglingSince.Add(policy.Spec.GracePeriod.Duration))
+}
+
+// UnusedForElapsed reports if a PVC that was last used at the given time is due for the policy action
+func UnusedForElapsed(policy *v1alpha1.PVCCleanupPolicy, lastUsed time.Time, now time.Time) bool {
+	if policy.Spec.UnusedFor == nil {
+		return true
+	}
+	return !now.Before(lastUsed.Add(policy.Spec.UnusedFor.Duration))
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/ksraj123/lister-sa/pkg/apis/pvccleaner/v1alpha1"
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/tests/generators"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		spec     v1alpha1.PVCCleanupPolicySpec
+		expected bool
+	}{
+		"Policy with defaults is valid": {
+			spec:     v1alpha1.PVCCleanupPolicySpec{},
+			expected: true,
+		},
+		"Policy with unknown action is invalid": {
+			spec:     v1alpha1.PVCCleanupPolicySpec{Action: "Archive"},
+			expected: false,
+		},
+		"Policy with negative grace period is invalid": {
+			spec:     v1alpha1.PVCCleanupPolicySpec{GracePeriod: &metav1.Duration{Duration: -time.Hour}},
+			expected: false,
+		},
+		"Policy with invalid selector operator is invalid": {
+			spec: v1alpha1.PVCCleanupPolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Like"}},
+				},
+			},
+			expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(&v1alpha1.PVCCleanupPolicy{Spec: test.spec})
+			if (err == nil) != test.expected {
+				t.Fatalf("Expected policy validity %v, got error %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestSelectsPVC(t *testing.T) {
+	storageClass := generators.GenerateStorageClass("test-sc", nil, map[string]string{constants.STS_PVC_SELECTOR: "sts-pvc"}, "test-provisioner")
+
+	tests := map[string]struct {
+		selector  *metav1.LabelSelector
+		pvcLabels map[string]string
+		expected  bool
+	}{
+		"Falls back to the sts-pvc-selector parameter": {
+			pvcLabels: map[string]string{"sts-pvc": "true"},
+			expected:  true,
+		},
+		"PVC without the sts-pvc-selector label is not selected": {
+			pvcLabels: map[string]string{"app": "mongo"},
+			expected:  false,
+		},
+		"Statefulset selector takes precedence over the parameter": {
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mongo"}},
+			pvcLabels: map[string]string{"app": "mongo"},
+			expected:  true,
+		},
+		"Statefulset selector not matching the PVC labels": {
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mongo"}},
+			pvcLabels: map[string]string{"sts-pvc": "true"},
+			expected:  false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cleanupPolicy := &v1alpha1.PVCCleanupPolicy{Spec: v1alpha1.PVCCleanupPolicySpec{StatefulSetSelector: test.selector}}
+			pvc := generators.GeneratePersistentVolumeClaim("test-pvc", constants.TEST_NAMESPACE, storageClass.Name, test.pvcLabels)
+			if observed := SelectsPVC(cleanupPolicy, pvc, storageClass); observed != test.expected {
+				t.Fatalf("Expected PVC selection %v, got %v", test.expected, observed)
+			}
+		})
+	}
+}
+
+func TestGracePeriodElapsed(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		gracePeriod   *metav1.Duration
+		danglingSince time.Time
+		expected      bool
+	}{
+		"No grace period": {
+			danglingSince: now,
+			expected:      true,
+		},
+		"Within the grace period": {
+			gracePeriod:   &metav1.Duration{Duration: time.Hour},
+			danglingSince: now.Add(-time.Minute),
+			expected:      false,
+		},
+		"Grace period elapsed": {
+			gracePeriod:   &metav1.Duration{Duration: time.Hour},
+			danglingSince: now.Add(-2 * time.Hour),
+			expected:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cleanupPolicy := &v1alpha1.PVCCleanupPolicy{Spec: v1alpha1.PVCCleanupPolicySpec{GracePeriod: test.gracePeriod}}
+			if observed := GracePeriodElapsed(cleanupPolicy, test.danglingSince, now); observed != test.expected {
+				t.Fatalf("Expected grace period elapsed %v, got %v", test.expected, observed)
+			}
+		})
+	}
+}
+
+func TestUnusedForElapsed(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		unusedFor *metav1.Duration
+		lastUsed  time.Time
+		expected  bool
+	}{
+		"No unusedFor": {
+			lastUsed: now,
+			expected: true,
+		},
+		"Used too recently": {
+			unusedFor: &metav1.Duration{Duration: 7 * 24 * time.Hour},
+			lastUsed:  now.Add(-24 * time.Hour),
+			expected:  false,
+		},
+		"Unused for long enough": {
+			unusedFor: &metav1.Duration{Duration: 7 * 24 * time.Hour},
+			lastUsed:  now.Add(-8 * 24 * time.Hour),
+			expected:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cleanupPolicy := &v1alpha1.PVCCleanupPolicy{Spec: v1alpha1.PVCCleanupPolicySpec{UnusedFor: test.unusedFor}}
+			if observed := UnusedForElapsed(cleanupPolicy, test.lastUsed, now); observed != test.expected {
+				t.Fatalf("Expected unused for elapsed %v, got %v", test.expected, observed)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package printers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	TABLE = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+)
+
+// TablePrinter is implemented by the plans and reports, JSON and YAML are rendered from their json tags
+type TablePrinter interface {
+	PrintTable(out io.Writer)
+}
+
+func ValidateFormat(format string) error {
+	switch format {
+	case TABLE, JSON, YAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %v, %v or %v", format, TABLE, JSON, YAML)
+}
+
+func Print(out io.Writer, format string, obj TablePrinter) error {
+	switch format {
+	case TABLE:
+		obj.PrintTable(out)
+		return nil
+	case JSON:
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case YAML:
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+	return ValidateFormat(format)
+}
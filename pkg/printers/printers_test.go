@@ -0,0 +1,58 @@
+package printers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type testObject struct {
+	Name string `json:"name"`
+}
+
+func (o testObject) PrintTable(out io.Writer) {
+	fmt.Fprintf(out, "NAME\n%v\n", o.Name)
+}
+
+func TestPrint(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		expected string
+		isError  bool
+	}{
+		"Table": {
+			format:   TABLE,
+			expected: "NAME\npvc-test-0\n",
+		},
+		"JSON": {
+			format:   JSON,
+			expected: "{\n  \"name\": \"pvc-test-0\"\n}\n",
+		},
+		"YAML": {
+			format:   YAML,
+			expected: "name: pvc-test-0\n",
+		},
+		"Unknown format": {
+			format:  "xml",
+			isError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := Print(out, test.format, testObject{Name: "pvc-test-0"})
+			if test.isError {
+				if err == nil || !strings.Contains(err.Error(), test.format) {
+					t.Fatalf("Expected error for output format %v, got %v", test.format, err)
+				}
+				return
+			}
+			if err != nil || out.String() != test.expected {
+				t.Fatalf("Expected %q, got %q, error %v", test.expected, out.String(), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package provisioners
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the LVM and ZFS engines label their snapshots with the volume they were taken of
+const volumeLabel = "openebs.io/persistent-volume"
+
+// The volume resources of the LVM and ZFS engines are accessed through the dynamic client, deleting one makes the
+// engine destroy the logical volume or dataset on the node
+var (
+	LVMVolumeResource   = schema.GroupVersionResource{Group: "local.openebs.io", Version: "v1alpha1", Resource: "lvmvolumes"}
+	LVMSnapshotResource = schema.GroupVersionResource{Group: "local.openebs.io", Version: "v1alpha1", Resource: "lvmsnapshots"}
+	ZFSVolumeResource   = schema.GroupVersionResource{Group: "zfs.openebs.io", Version: "v1", Resource: "zfsvolumes"}
+	ZFSSnapshotResource = schema.GroupVersionResource{Group: "zfs.openebs.io", Version: "v1", Resource: "zfssnapshots"}
+)
+
+func init() {
+	Register(&hostpath{})
+	Register(&csiEngine{provisioner: constants.LVM_PROVISIONER, nodeKey: "openebs.io/nodename", volumes: LVMVolumeResource, snapshots: LVMSnapshotResource})
+	Register(&csiEngine{provisioner: constants.ZFS_PROVISIONER, nodeKey: "openebs.io/nodeid", volumes: ZFSVolumeResource, snapshots: ZFSSnapshotResource})
+}
+
+// hostpath is the OpenEBS local PV engine backed by a directory on the node
+type hostpath struct{}
+
+func (h *hostpath) Provisioner() string {
+	return constants.HOSTPATH_PROVISIONER
+}
+
+func (h *hostpath) Node(pv *v1.PersistentVolume) string {
+	return utils.VolumeNodeOn(pv, "kubernetes.io/hostname")
+}
+
+// the directory of the volume is only removed by the provisioner, a volume without one is not an OpenEBS local PV
+func (h *hostpath) SafetyCheck(ctx context.Context, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	path := hostpathDirectory(pv)
+	if path == "" || filepath.Clean(path) == "/" {
+		return fmt.Errorf("volume %v has no hostpath directory", pv.Name)
+	}
+	return nil
+}
+
+// the directory can only be removed from the node, it is left there
+func (h *hostpath) Cleanup(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	if err := deleteVolume(clientset, ctx, pv); err != nil {
+		return err
+	}
+	fmt.Printf("Volume %v deleted, its directory %v on node %v has to be removed by hand\n", pv.Name, hostpathDirectory(pv), h.Node(pv))
+	return nil
+}
+
+func hostpathDirectory(pv *v1.PersistentVolume) string {
+	if pv.Spec.Local != nil {
+		return pv.Spec.Local.Path
+	}
+	if pv.Spec.HostPath != nil {
+		return pv.Spec.HostPath.Path
+	}
+	return ""
+}
+
+// csiEngine is an OpenEBS CSI engine that keeps a volume resource per volume in the OpenEBS namespace
+type csiEngine struct {
+	provisioner string
+	nodeKey     string
+	volumes     schema.GroupVersionResource
+	snapshots   schema.GroupVersionResource
+}
+
+func (e *csiEngine) Provisioner() string {
+	return e.provisioner
+}
+
+func (e *csiEngine) Node(pv *v1.PersistentVolume) string {
+	return utils.VolumeNodeOn(pv, e.nodeKey)
+}
+
+// a volume with snapshots can not be destroyed by the engine
+func (e *csiEngine) SafetyCheck(ctx context.Context, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	if dynamicClient == nil {
+		return fmt.Errorf("no client to check the snapshots of volume %v", pv.Name)
+	}
+	snapshots, err := dynamicClient.Resource(e.snapshots).Namespace(constants.OPENEBS_NAMESPACe).List(ctx, metav1.ListOptions{LabelSelector: volumeLabel + "=" + pv.Name})
+	if err != nil {
+		return fmt.Errorf("could not check the snapshots of volume %v, %v", pv.Name, err.Error())
+	}
+	if len(snapshots.Items) != 0 {
+		return fmt.Errorf("volume %v has %v snapshots", pv.Name, len(snapshots.Items))
+	}
+	return nil
+}
+
+// deleting the volume resource destroys the logical volume or dataset on the node
+func (e *csiEngine) Cleanup(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	if err := deleteVolume(clientset, ctx, pv); err != nil {
+		return err
+	}
+	if dynamicClient == nil {
+		return fmt.Errorf("no client to delete %v %v", e.volumes.Resource, pv.Name)
+	}
+	name := pv.Name
+	if pv.Spec.CSI != nil {
+		name = pv.Spec.CSI.VolumeHandle
+	}
+	err := dynamicClient.Resource(e.volumes).Namespace(constants.OPENEBS_NAMESPACe).Delete(ctx, name, metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
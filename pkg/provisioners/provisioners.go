@@ -0,0 +1,97 @@
+// Package provisioners holds what the cleaner knows about the volumes of each provisioner: which node a volume is bound to,
+// what has to be checked before its PVC is deleted and how to clean up what a Retain volume leaves behind
+package provisioners
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Plugin is implemented once per provisioner
+type Plugin interface {
+	// Provisioner is the StorageClass provisioner, or CSI driver, the plugin is for
+	Provisioner() string
+	// Node returns the node the volume is bound to, empty if it is not bound to a single node
+	Node(pv *v1.PersistentVolume) string
+	// SafetyCheck returns why the PVC bound to the volume must not be deleted, nil if it may
+	SafetyCheck(ctx context.Context, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error
+	// Cleanup deletes a Retain volume whose PVC was deleted along with the resources backing it
+	Cleanup(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error
+}
+
+var plugins = make(map[string]Plugin)
+
+// Register adds a plugin, replacing the one registered for the same provisioner
+func Register(plugin Plugin) {
+	plugins[plugin.Provisioner()] = plugin
+}
+
+// For returns the plugin of the provisioner, a generic one if none is registered
+func For(provisioner string) Plugin {
+	if plugin, exists := plugins[provisioner]; exists {
+		return plugin
+	}
+	return &generic{provisioner: provisioner}
+}
+
+// ForVolume returns the plugin of the provisioner that provisioned the volume
+func ForVolume(pv *v1.PersistentVolume) Plugin {
+	if pv.Spec.CSI != nil {
+		return For(pv.Spec.CSI.Driver)
+	}
+	return For(pv.Annotations["pv.kubernetes.io/provisioned-by"])
+}
+
+// Configured returns the provisioners of the PROVISIONERS environment variable, the provisioners of all registered
+// plugins if it is not set
+func Configured() []string {
+	if _, exists := os.LookupEnv(constants.PROVISIONERS_ENV_VAR); exists {
+		return utils.EnvVarSlice(constants.PROVISIONERS_ENV_VAR)
+	}
+	var provisioners []string
+	for provisioner := range plugins {
+		provisioners = append(provisioners, provisioner)
+	}
+	sort.Strings(provisioners)
+	return provisioners
+}
+
+// generic is used for provisioners without a plugin, its volumes are deleted by the provisioner or not at all
+type generic struct {
+	provisioner string
+}
+
+func (g *generic) Provisioner() string {
+	return g.provisioner
+}
+
+func (g *generic) Node(pv *v1.PersistentVolume) string {
+	return utils.VolumeNode(pv)
+}
+
+func (g *generic) SafetyCheck(ctx context.Context, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	return nil
+}
+
+// only the PV is deleted, what backs it is left to the provisioner
+func (g *generic) Cleanup(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, pv *v1.PersistentVolume) error {
+	return deleteVolume(clientset, ctx, pv)
+}
+
+// a volume already deleted by its reclaim policy is not an error, what backs it may still have to be cleaned up
+func deleteVolume(clientset kubernetes.Interface, ctx context.Context, pv *v1.PersistentVolume) error {
+	err := clientset.CoreV1().PersistentVolumes().Delete(ctx, pv.Name, metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
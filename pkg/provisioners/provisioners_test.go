@@ -0,0 +1,103 @@
+package provisioners
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func generateVolume(name string, driver string, nodeKey string, node string) *v1.PersistentVolume {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			NodeAffinity: &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+				MatchExpressions: []v1.NodeSelectorRequirement{{Key: nodeKey, Operator: v1.NodeSelectorOpIn, Values: []string{node}}},
+			}}}},
+		},
+	}
+	if driver == constants.HOSTPATH_PROVISIONER {
+		pv.Annotations = map[string]string{"pv.kubernetes.io/provisioned-by": driver}
+		pv.Spec.Local = &v1.LocalVolumeSource{Path: "/var/openebs/local/" + name}
+	} else {
+		pv.Spec.CSI = &v1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name}
+	}
+	return pv
+}
+
+func generateSnapshot(resource schema.GroupVersionResource, kind string, volume string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": resource.GroupVersion().String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      "snapshot-" + volume,
+			"namespace": constants.OPENEBS_NAMESPACe,
+			"labels":    map[string]interface{}{volumeLabel: volume},
+		},
+	}}
+}
+
+func TestPlugins(t *testing.T) {
+	zfsWithSnapshot := generateVolume("pv-zfs-snapshotted", constants.ZFS_PROVISIONER, "openebs.io/nodeid", "node-2")
+	hostpathWithoutDirectory := generateVolume("pv-hostpath-root", constants.HOSTPATH_PROVISIONER, "kubernetes.io/hostname", "node-1")
+	hostpathWithoutDirectory.Spec.Local.Path = "/"
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		LVMSnapshotResource: "LVMSnapshotList",
+		ZFSSnapshotResource: "ZFSSnapshotList",
+	}, generateSnapshot(ZFSSnapshotResource, "ZFSSnapshot", zfsWithSnapshot.Name))
+
+	tests := map[string]struct {
+		pv                  *v1.PersistentVolume
+		expectedProvisioner string
+		expectedNode        string
+		expectSafe          bool
+	}{
+		"Hostpath volume": {
+			pv:                  generateVolume("pv-hostpath", constants.HOSTPATH_PROVISIONER, "kubernetes.io/hostname", "node-1"),
+			expectedProvisioner: constants.HOSTPATH_PROVISIONER,
+			expectedNode:        "node-1",
+			expectSafe:          true,
+		},
+		"Hostpath volume without a directory is not safe": {
+			pv:                  hostpathWithoutDirectory,
+			expectedProvisioner: constants.HOSTPATH_PROVISIONER,
+			expectedNode:        "node-1",
+		},
+		"LVM volume": {
+			pv:                  generateVolume("pv-lvm", constants.LVM_PROVISIONER, "openebs.io/nodename", "node-3"),
+			expectedProvisioner: constants.LVM_PROVISIONER,
+			expectedNode:        "node-3",
+			expectSafe:          true,
+		},
+		"ZFS volume with a snapshot is not safe": {
+			pv:                  zfsWithSnapshot,
+			expectedProvisioner: constants.ZFS_PROVISIONER,
+			expectedNode:        "node-2",
+		},
+		"Volume of a provisioner without a plugin": {
+			pv:                  generateVolume("pv-other", "example.com/other", "kubernetes.io/hostname", "node-4"),
+			expectedProvisioner: "example.com/other",
+			expectedNode:        "node-4",
+			expectSafe:          true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			plugin := ForVolume(test.pv)
+			if plugin.Provisioner() != test.expectedProvisioner || plugin.Node(test.pv) != test.expectedNode {
+				t.Fatalf("Expected provisioner %v and node %v, got %v and %v", test.expectedProvisioner, test.expectedNode, plugin.Provisioner(), plugin.Node(test.pv))
+			}
+			err := plugin.SafetyCheck(context.Background(), dynamicClient, test.pv)
+			if (err == nil) != test.expectSafe {
+				t.Fatalf("Expected safety check to pass %v, got %v", test.expectSafe, err)
+			}
+		})
+	}
+}
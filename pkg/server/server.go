@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/executor"
+)
+
+// Options are the data the endpoints are served from
+type Options struct {
+	// Ready returns why the process is not ready, always ready if nil
+	Ready func() error
+	// Plan computes the current plan served on /debug/plan, not served if nil
+	Plan func(ctx context.Context) (*executor.CleanupPlan, error)
+}
+
+// Server serves the health and readiness endpoints, or the debug endpoints, of the long running mode
+type Server struct {
+	server  *http.Server
+	mux     *http.ServeMux
+	options Options
+}
+
+// New serves only /healthz and /readyz, for the probes of the kubelet
+func New(addr string, options Options) *Server {
+	s := newServer(addr, options)
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	return s
+}
+
+// NewDebug serves /debug/plan and /debug/pprof, they are not authenticated so addr should only be reachable from the pod
+func NewDebug(addr string, options Options) *Server {
+	s := newServer(addr, options)
+	s.mux.HandleFunc("/debug/plan", s.plan)
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return s
+}
+
+func newServer(addr string, options Options) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		server:  &http.Server{Addr: addr, Handler: mux},
+		mux:     mux,
+		options: options,
+	}
+}
+
+// Start serves in the background until ctx is done
+func (s *Server) Start(ctx context.Context) {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("HTTP server on %v stopped, Error = %v\n", s.server.Addr, err.Error())
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), constants.FINISH_TIMEOUT)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+}
+
+// the process is healthy as long as it serves
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if s.options.Ready != nil {
+		if err := s.options.Ready(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// plans with the request context, nothing is changed in the cluster
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	if s.options.Plan == nil {
+		http.NotFound(w, r)
+		return
+	}
+	plan, err := s.options.Plan(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(plan)
+}
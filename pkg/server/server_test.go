@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksraj123/lister-sa/pkg/executor"
+)
+
+func TestEndpoints(t *testing.T) {
+	plan := &executor.CleanupPlan{Entries: []executor.PlanEntry{{Namespace: "default", Name: "data-web-0", Dangling: true, Action: "Delete"}}}
+	tests := map[string]struct {
+		options Options
+		// debug serves the debug endpoints instead of the probes
+		debug          bool
+		path           string
+		expectedStatus int
+	}{
+		"healthz": {
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+		},
+		"readyz without a readiness check": {
+			path:           "/readyz",
+			expectedStatus: http.StatusOK,
+		},
+		"readyz when ready": {
+			options:        Options{Ready: func() error { return nil }},
+			path:           "/readyz",
+			expectedStatus: http.StatusOK,
+		},
+		"readyz when not ready": {
+			options:        Options{Ready: func() error { return errors.New("API server not reachable yet") }},
+			path:           "/readyz",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		"plan": {
+			options: Options{Plan: func(ctx context.Context) (*executor.CleanupPlan, error) {
+				return plan, nil
+			}},
+			debug:          true,
+			path:           "/debug/plan",
+			expectedStatus: http.StatusOK,
+		},
+		"plan failed": {
+			options: Options{Plan: func(ctx context.Context) (*executor.CleanupPlan, error) {
+				return nil, executor.ErrNoStorageClasses
+			}},
+			debug:          true,
+			path:           "/debug/plan",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		"plan without a planner": {
+			debug:          true,
+			path:           "/debug/plan",
+			expectedStatus: http.StatusNotFound,
+		},
+		"pprof": {
+			debug:          true,
+			path:           "/debug/pprof/",
+			expectedStatus: http.StatusOK,
+		},
+		"plan is not served with the probes": {
+			options: Options{Plan: func(ctx context.Context) (*executor.CleanupPlan, error) {
+				return plan, nil
+			}},
+			path:           "/debug/plan",
+			expectedStatus: http.StatusNotFound,
+		},
+		"pprof is not served with the probes": {
+			path:           "/debug/pprof/",
+			expectedStatus: http.StatusNotFound,
+		},
+		"healthz is not served with the debug endpoints": {
+			debug:          true,
+			path:           "/healthz",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server := New(":0", test.options)
+			if test.debug {
+				server = NewDebug(":0", test.options)
+			}
+			server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %v, got %v: %v", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.path == "/debug/plan" && w.Code == http.StatusOK {
+				var served executor.CleanupPlan
+				if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil {
+					t.Fatal(err)
+				}
+				if len(served.Entries) != 1 || served.Entries[0].Name != "data-web-0" {
+					t.Errorf("expected the plan to be served, got %+v", served)
+				}
+			}
+		})
+	}
+}
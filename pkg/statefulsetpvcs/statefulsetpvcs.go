@@ -2,8 +2,9 @@ package statefulsetpvcs
 
 import (
 	"context"
+	"strings"
 
-	"github.com/ksraj123/lister-sa/pkg/constants"
+	"github.com/ksraj123/lister-sa/pkg/engine"
 	v1 "k8s.io/api/core/v1"
 	StorageV1 "k8s.io/api/storage/v1"
 	"k8s.io/client-go/kubernetes"
@@ -12,15 +13,32 @@ import (
 // Kubernetes copies Statefulset selector as labels on statefulset PVCs, this property helps determine if the PVC is a statefulset PVC
 // there being no other way to do so once the statefulset itself by virtue of which the PVCs were created gets deleted
 // an extra selector needs to be put on the sts whose name can would be the value of "sts-pvc-selector" parameter of storage class and value could be true
-func GetStatefulSetPVCs(clientset *kubernetes.Clientset, ctx context.Context, pvcs []v1.PersistentVolumeClaim, openEbsStorageClassesMap map[string]*StorageV1.StorageClass) []v1.PersistentVolumeClaim {
+func GetStatefulSetPVCs(clientset kubernetes.Interface, ctx context.Context, pvcs []v1.PersistentVolumeClaim, openEbsStorageClassesMap map[string]*StorageV1.StorageClass) []v1.PersistentVolumeClaim {
 	var statefulsetPvcs []v1.PersistentVolumeClaim
 	for _, pvc := range pvcs {
-		statefulsetPvcSelector := openEbsStorageClassesMap[*pvc.Spec.StorageClassName].Parameters[constants.STS_PVC_SELECTOR]
-		for key, value := range pvc.Labels {
-			if key == statefulsetPvcSelector && value == "true" {
-				statefulsetPvcs = append(statefulsetPvcs, pvc)
-			}
+		if engine.IsStatefulSetPVC(&pvc, openEbsStorageClassesMap[*pvc.Spec.StorageClassName]) {
+			statefulsetPvcs = append(statefulsetPvcs, pvc)
 		}
 	}
 	return statefulsetPvcs
 }
+
+// Returns the name of the StatefulSet the PVC was created for, from its lineage if it was recorded. A StatefulSet PVC is
+// named <claim template>-<statefulset>-<ordinal>, without the lineage the claim template is not known once the StatefulSet
+// is gone so the name is a best guess if the claim template contains a dash
+func OwnerStatefulSet(pvc *v1.PersistentVolumeClaim) string {
+	if lineage := engine.PVCLineage(pvc); lineage != nil {
+		return lineage.StatefulSet
+	}
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return owner.Name
+		}
+	}
+	ordinalIndex := strings.LastIndex(pvc.Name, "-")
+	templateIndex := strings.Index(pvc.Name, "-")
+	if _, found := engine.Ordinal(pvc.Name); !found || ordinalIndex <= templateIndex {
+		return ""
+	}
+	return pvc.Name[templateIndex+1 : ordinalIndex]
+}
@@ -102,3 +102,36 @@ func TestGetStatefulSetPVCs(t *testing.T) {
 		})
 	}
 }
+
+func TestOwnerStatefulSet(t *testing.T) {
+	tests := map[string]struct {
+		pvcName  string
+		expected string
+	}{
+		"PVC of a StatefulSet": {
+			pvcName:  "pvc-mongo-0",
+			expected: "mongo",
+		},
+		"PVC of a StatefulSet with a dash in its name": {
+			pvcName:  "data-mongo-shard-12",
+			expected: "mongo-shard",
+		},
+		"PVC without an ordinal": {
+			pvcName:  "data-mongo",
+			expected: "",
+		},
+		"PVC without a claim template": {
+			pvcName:  "mongo",
+			expected: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pvc := generators.GeneratePersistentVolumeClaim(test.pvcName, constants.TEST_NAMESPACE, "test-storage-class", nil)
+			if observed := OwnerStatefulSet(pvc); observed != test.expected {
+				t.Fatalf("Expected owner StatefulSet %q, got %q", test.expected, observed)
+			}
+		})
+	}
+}
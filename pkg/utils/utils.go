@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
 )
 
 func EnvVarSlice(envVarName string) []string {
@@ -14,3 +18,33 @@ func EnvVarSlice(envVarName string) []string {
 	slice := strings.Split(envVar, ",")
 	return slice
 }
+
+// FinishContext returns ctx while it is not done, otherwise a new context bounded by FINISH_TIMEOUT, so that a stopped
+// run can still record what it did
+func FinishContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Err() == nil {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(context.Background(), constants.FINISH_TIMEOUT)
+}
+
+// GraceContext returns a context that is not cancelled with ctx, so that an API call started before a run was stopped
+// is not abandoned halfway, but that is cancelled FINISH_TIMEOUT after ctx is done, like a FinishContext
+func GraceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-graceCtx.Done():
+			return
+		}
+		timer := time.NewTimer(constants.FINISH_TIMEOUT)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graceCtx.Done():
+		}
+	}()
+	return graceCtx, cancel
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// node topology keys used by the OpenEBS local PV engines, in the order they are looked up
+var nodeTopologyKeys = []string{"kubernetes.io/hostname", "openebs.io/nodename", "openebs.io/nodeid"}
+
+// Returns the node a local PV is pinned to by its node affinity, empty if it is not pinned to a single node
+func VolumeNode(pv *v1.PersistentVolume) string {
+	for _, key := range nodeTopologyKeys {
+		if node := VolumeNodeOn(pv, key); node != "" {
+			return node
+		}
+	}
+	return ""
+}
+
+// Returns the single value of the node affinity of the PV on the topology key, empty if there is none
+func VolumeNodeOn(pv *v1.PersistentVolume, key string) string {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return ""
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == key && expression.Operator == v1.NodeSelectorOpIn && len(expression.Values) == 1 {
+				return strings.TrimSpace(expression.Values[0])
+			}
+		}
+	}
+	return ""
+}
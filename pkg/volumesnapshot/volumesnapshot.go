@@ -0,0 +1,74 @@
+package volumesnapshot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ksraj123/lister-sa/pkg/constants"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// VolumeSnapshots are accessed through the dynamic client to avoid depending on the external-snapshotter client
+var VolumeSnapshotResource = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+// Name of the VolumeSnapshot taken of a dangling PVC, it holds the UID of the PVC so that a PVC recreated under the same
+// name gets a snapshot of its own
+func Name(pvc *v1.PersistentVolumeClaim) string {
+	return fmt.Sprintf("%v-pvc-cleaner-%v", pvc.Name, pvc.UID)
+}
+
+// Create takes a snapshot of the PVC, labelled with the UID of the PVC. An error without a dynamic client
+func Create(dynamicClient dynamic.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim, snapshotClassName string) error {
+	if dynamicClient == nil {
+		return fmt.Errorf("no client to snapshot PVC %v", pvc.Name)
+	}
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvc.Name,
+		},
+	}
+	if snapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = snapshotClassName
+	}
+	snapshot := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": VolumeSnapshotResource.GroupVersion().String(),
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      Name(pvc),
+				"namespace": pvc.Namespace,
+				"labels": map[string]interface{}{
+					constants.SOURCE_PVC_UID_LABEL: string(pvc.UID),
+				},
+			},
+			"spec": spec,
+		},
+	}
+	_, err := dynamicClient.Resource(VolumeSnapshotResource).Namespace(pvc.Namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+	return err
+}
+
+// Reports if a snapshot of the PVC was taken and if it is ready to use. A snapshot of the name that is not labelled with
+// the UID of the PVC was not taken by the cleaner of this PVC and is an error, as is a missing dynamic client
+func Status(dynamicClient dynamic.Interface, ctx context.Context, pvc *v1.PersistentVolumeClaim) (exists bool, ready bool, err error) {
+	if dynamicClient == nil {
+		return false, false, fmt.Errorf("no client to get the snapshot of PVC %v", pvc.Name)
+	}
+	snapshot, err := dynamicClient.Resource(VolumeSnapshotResource).Namespace(pvc.Namespace).Get(ctx, Name(pvc), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if uid := snapshot.GetLabels()[constants.SOURCE_PVC_UID_LABEL]; uid != string(pvc.UID) {
+		return false, false, fmt.Errorf("snapshot %v was taken of PVC uid %q, not %v", snapshot.GetName(), uid, pvc.UID)
+	}
+	ready, _, err = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return true, ready, err
+}